@@ -14,6 +14,7 @@ import (
 	"github.com/cryptoriums/wallger/pkg/cli"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/go-kit/log"
+	"github.com/pkg/errors"
 	"github.com/posener/complete"
 	"github.com/willabides/kongplete"
 )
@@ -35,5 +36,22 @@ func main() {
 
 	ctx, err := parser.Parse(os.Args[1:])
 	parser.FatalIfErrorf(err)
-	ctx.FatalIfErrorf(ctx.Run(*ctx))
+	err = ctx.Run(*ctx)
+	if err != nil {
+		// Scripts can tell a reverted TX from the other errors.
+		exitCode := 1
+		if errors.Is(err, cli.ErrReverted) {
+			exitCode = cli.ExitCodeReverted
+		}
+		if cli.CLIInstance.Output == cli.OutputJSON {
+			if err := cli.CLIInstance.Print(cli.ErrorResult{Error: err.Error()}); err == nil {
+				os.Exit(exitCode)
+			}
+		}
+		if exitCode != 1 {
+			ctx.Errorf("%s", err)
+			os.Exit(exitCode)
+		}
+	}
+	ctx.FatalIfErrorf(err)
 }
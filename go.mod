@@ -9,6 +9,7 @@ require (
 	github.com/cryptoriums/packages v0.0.0-20240112154437-df32d8e273bd
 	github.com/ethereum/go-ethereum v1.10.25
 	github.com/go-kit/log v0.2.1
+	github.com/google/uuid v1.3.0
 	github.com/jinzhu/copier v0.3.6-0.20220210061904-7948fe2be217
 	github.com/pkg/errors v0.9.1
 	github.com/posener/complete v1.2.3
@@ -25,7 +26,6 @@ require (
 	github.com/go-logfmt/logfmt v0.5.1 // indirect
 	github.com/go-ole/go-ole v1.2.6 // indirect
 	github.com/go-stack/stack v1.8.1 // indirect
-	github.com/google/uuid v1.3.0 // indirect
 	github.com/gorilla/websocket v1.5.0 // indirect
 	github.com/hashicorp/errwrap v1.1.0 // indirect
 	github.com/hashicorp/go-multierror v1.1.1 // indirect
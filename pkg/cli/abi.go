@@ -0,0 +1,419 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+)
+
+// loadABI reads the ABI from a JSON file or parses a human readable
+// function signature like "balanceOf(address) returns (uint256)".
+func loadABI(input string) (abi.ABI, error) {
+	if strings.Contains(input, "(") {
+		return parseSignatureABI(input)
+	}
+	content, err := os.ReadFile(input)
+	if err != nil {
+		return abi.ABI{}, errors.Wrap(err, "read ABI file")
+	}
+	return parseABI(content)
+}
+
+// abiJSON returns the JSON ABI from a JSON ABI or a hardhat/truffle artifact with an abi field.
+func abiJSON(content []byte) json.RawMessage {
+	var artifact struct {
+		ABI json.RawMessage `json:"abi"`
+	}
+	if err := json.Unmarshal(content, &artifact); err == nil && len(artifact.ABI) > 0 {
+		return artifact.ABI
+	}
+	return content
+}
+
+// parseABI parses a JSON ABI or a hardhat/truffle artifact with an abi field.
+func parseABI(content []byte) (abi.ABI, error) {
+	var parsed abi.ABI
+	if err := json.Unmarshal(abiJSON(content), &parsed); err != nil {
+		return abi.ABI{}, errors.Wrap(err, "parse ABI")
+	}
+	return parsed, nil
+}
+
+// parseSignatureABI creates an ABI with a single function from its human readable signature.
+// Parameter names and the function keyword are optional.
+func parseSignatureABI(sig string) (abi.ABI, error) {
+	sig = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(sig), "function "))
+	name, rest, ok := strings.Cut(sig, "(")
+	if !ok || name == "" {
+		return abi.ABI{}, errors.Errorf("invalid function signature:%v", sig)
+	}
+	inputs, rest, err := signatureParams("(" + rest)
+	if err != nil {
+		return abi.ABI{}, errors.Wrapf(err, "function signature:%v", sig)
+	}
+
+	mutability := "nonpayable"
+	var outputs []abi.Argument
+	for _, word := range strings.Fields(strings.SplitN(rest, "(", 2)[0]) {
+		switch word {
+		case "view", "pure", "payable":
+			mutability = word
+		case "returns", "external", "public":
+		default:
+			return abi.ABI{}, errors.Errorf("unknown function signature keyword:%v", word)
+		}
+	}
+	if _, returns, ok := strings.Cut(rest, "returns"); ok {
+		outputs, _, err = signatureParams(strings.TrimSpace(returns))
+		if err != nil {
+			return abi.ABI{}, errors.Wrapf(err, "function signature returns:%v", sig)
+		}
+	}
+
+	method := abi.NewMethod(name, name, abi.Function, mutability, false, mutability == "payable", inputs, outputs)
+	return abi.ABI{Methods: map[string]abi.Method{name: method}}, nil
+}
+
+// signatureParams parses the parenthesized parameters at the start of the input
+// and returns the remaining input.
+func signatureParams(input string) (abi.Arguments, string, error) {
+	if !strings.HasPrefix(input, "(") {
+		return nil, "", errors.Errorf("missing parameters:%v", input)
+	}
+	end := strings.Index(input, ")")
+	if end < 0 {
+		return nil, "", errors.Errorf("missing closing parenthesis:%v", input)
+	}
+	if strings.Contains(input[1:end], "(") {
+		return nil, "", errors.New("tuple parameters require a JSON ABI")
+	}
+
+	var args abi.Arguments
+	if params := strings.TrimSpace(input[1:end]); params != "" {
+		for _, param := range strings.Split(params, ",") {
+			fields := strings.Fields(param)
+			if len(fields) == 0 {
+				return nil, "", errors.Errorf("empty parameter:%v", input)
+			}
+			typ, err := abi.NewType(fields[0], "", nil)
+			if err != nil {
+				return nil, "", errors.Wrapf(err, "parameter type:%v", fields[0])
+			}
+			// Skip the data location like in "string memory name".
+			var name string
+			if len(fields) > 1 {
+				name = fields[len(fields)-1]
+			}
+			args = append(args, abi.Argument{Name: name, Type: typ})
+		}
+	}
+	return args, input[end+1:], nil
+}
+
+// findMethod returns the method by its name or by its signature like "transfer(address,uint256)".
+func findMethod(contractABI abi.ABI, input string) (abi.Method, error) {
+	if strings.Contains(input, "(") {
+		sig := strings.ReplaceAll(input, " ", "")
+		for _, method := range contractABI.Methods {
+			if method.Sig == sig {
+				return method, nil
+			}
+		}
+		return abi.Method{}, errors.Errorf("method not in the ABI:%v", input)
+	}
+	method, ok := contractABI.Methods[input]
+	if !ok {
+		return abi.Method{}, errors.Errorf("method not in the ABI:%v", input)
+	}
+	return method, nil
+}
+
+// packMethod encodes the call data of the method given by its signature.
+func packMethod(sig string, args ...interface{}) ([]byte, error) {
+	contractABI, err := parseSignatureABI(sig)
+	if err != nil {
+		return nil, err
+	}
+	for _, method := range contractABI.Methods {
+		input, err := method.Inputs.Pack(args...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "pack method:%v", method.Sig)
+		}
+		return append(method.ID, input...), nil
+	}
+	return nil, errors.Errorf("no method in the signature:%v", sig)
+}
+
+// callMethod calls the read only method given by its signature and returns the decoded outputs.
+func callMethod(ctx context.Context, client bind.ContractCaller, contract common.Address, sig string, args ...interface{}) ([]interface{}, error) {
+	contractABI, err := parseSignatureABI(sig)
+	if err != nil {
+		return nil, err
+	}
+	for _, method := range contractABI.Methods {
+		input, err := method.Inputs.Pack(args...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "pack method:%v", method.Sig)
+		}
+		output, err := client.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: append(method.ID, input...)}, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "call method:%v", method.Sig)
+		}
+		values, err := method.Outputs.Unpack(output)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unpack method:%v output", method.Sig)
+		}
+		if len(values) != len(method.Outputs) {
+			return nil, errors.Errorf("method:%v returned %v values", method.Sig, len(values))
+		}
+		return values, nil
+	}
+	return nil, errors.Errorf("no method in the signature:%v", sig)
+}
+
+// parseArgs converts the string arguments to the Go types of the ABI arguments.
+// Arrays are given as JSON arrays like [1,2] or ["0x..","0x.."].
+func parseArgs(args abi.Arguments, inputs []string) ([]interface{}, error) {
+	if len(args) != len(inputs) {
+		return nil, errors.Errorf("expected %v arguments, got:%v", len(args), len(inputs))
+	}
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		v, err := parseArg(arg.Type, inputs[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "argument %v%v", i+1, strings.TrimRight(" "+arg.Name, " "))
+		}
+		values[i] = v.Interface()
+	}
+	return values, nil
+}
+
+func parseArg(t abi.Type, input string) (reflect.Value, error) {
+	input = strings.TrimSpace(input)
+	switch t.T {
+	case abi.AddressTy:
+		addr, err := parseAddress(input)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(addr), nil
+	case abi.IntTy, abi.UintTy:
+		v, ok := new(big.Int).SetString(input, 0)
+		if !ok {
+			return reflect.Value{}, errors.Errorf("invalid integer:%v", input)
+		}
+		if t.T == abi.UintTy && v.Sign() < 0 {
+			return reflect.Value{}, errors.Errorf("negative unsigned integer:%v", input)
+		}
+		if t.T == abi.UintTy && v.BitLen() > t.Size {
+			return reflect.Value{}, errors.Errorf("integer:%v overflows %v", input, t)
+		}
+		// Signed integers are in the range -2^(n-1) to 2^(n-1)-1.
+		if t.T == abi.IntTy {
+			limit := new(big.Int).Lsh(big.NewInt(1), uint(t.Size-1))
+			if v.Cmp(limit) >= 0 || v.Cmp(new(big.Int).Neg(limit)) < 0 {
+				return reflect.Value{}, errors.Errorf("integer:%v overflows %v", input, t)
+			}
+		}
+		// The ABI encoder requires the exact Go types for the integers up to 64 bits.
+		if t.GetType() == reflect.TypeOf(v) {
+			return reflect.ValueOf(v), nil
+		}
+		if t.T == abi.UintTy {
+			return reflect.ValueOf(v.Uint64()).Convert(t.GetType()), nil
+		}
+		return reflect.ValueOf(v.Int64()).Convert(t.GetType()), nil
+	case abi.BoolTy:
+		v, err := strconv.ParseBool(input)
+		if err != nil {
+			return reflect.Value{}, errors.Wrap(err, "invalid bool")
+		}
+		return reflect.ValueOf(v), nil
+	case abi.StringTy:
+		return reflect.ValueOf(input), nil
+	case abi.BytesTy:
+		v, err := hexutil.Decode(input)
+		if err != nil {
+			return reflect.Value{}, errors.Wrap(err, "invalid bytes")
+		}
+		return reflect.ValueOf(v), nil
+	case abi.FixedBytesTy:
+		v, err := hexutil.Decode(input)
+		if err != nil {
+			return reflect.Value{}, errors.Wrap(err, "invalid bytes")
+		}
+		if len(v) != t.Size {
+			return reflect.Value{}, errors.Errorf("expected %v bytes, got:%v", t.Size, len(v))
+		}
+		fixed := reflect.New(t.GetType()).Elem()
+		reflect.Copy(fixed, reflect.ValueOf(v))
+		return fixed, nil
+	case abi.SliceTy, abi.ArrayTy:
+		var items []json.RawMessage
+		if err := json.Unmarshal([]byte(input), &items); err != nil {
+			return reflect.Value{}, errors.Wrap(err, "arrays should be JSON arrays")
+		}
+		if t.T == abi.ArrayTy && len(items) != t.Size {
+			return reflect.Value{}, errors.Errorf("expected %v items, got:%v", t.Size, len(items))
+		}
+		arr := reflect.New(t.GetType()).Elem()
+		if t.T == abi.SliceTy {
+			arr = reflect.MakeSlice(t.GetType(), len(items), len(items))
+		}
+		for i, item := range items {
+			// Items can be JSON strings or plain values like numbers and bools.
+			var s string
+			if err := json.Unmarshal(item, &s); err != nil {
+				s = string(item)
+			}
+			v, err := parseArg(*t.Elem, s)
+			if err != nil {
+				return reflect.Value{}, errors.Wrapf(err, "item %v", i)
+			}
+			arr.Index(i).Set(v)
+		}
+		return arr, nil
+	}
+	return reflect.Value{}, errors.Errorf("unsupported argument type:%v", t)
+}
+
+// formatValues pairs the decoded values with the ABI arguments.
+func formatValues(args abi.Arguments, values []interface{}) []ContractValue {
+	result := make([]ContractValue, len(values))
+	for i, v := range values {
+		result[i] = ContractValue{Name: args[i].Name, Type: args[i].Type.String(), Value: formatValue(v)}
+	}
+	return result
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case *big.Int:
+		return v.String()
+	case common.Address:
+		return v.Hex()
+	case common.Hash:
+		return v.Hex()
+	case []byte:
+		return hexutil.Encode(v)
+	case string:
+		return v
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Array:
+		// Fixed bytes.
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return hexutil.Encode(b)
+		}
+		fallthrough
+	case reflect.Slice:
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = formatValue(rv.Index(i).Interface())
+		}
+		return "[" + strings.Join(items, ",") + "]"
+	}
+	return fmt.Sprint(v)
+}
+
+// decodeCall decodes the call data of a method that is in the ABIs like "transfer(to:0x.., amount:1)".
+// It returns an empty string when the method is unknown.
+func decodeCall(data []byte, abis ...abi.ABI) string {
+	if len(data) < 4 {
+		return ""
+	}
+	for _, contractABI := range abis {
+		method, err := contractABI.MethodById(data[:4])
+		if err != nil {
+			continue
+		}
+		values, err := method.Inputs.Unpack(data[4:])
+		if err != nil {
+			continue
+		}
+		return method.Name + "(" + formatContractValues(formatValues(method.Inputs, values)) + ")"
+	}
+	return ""
+}
+
+// decodeLogs decodes the events of the logs that are in the ABIs
+// trying first the env ABI of the contract that emitted the log.
+// Logs of unknown events contain only the event ID.
+func decodeLogs(logs []*types.Log, registry map[common.Address]abi.ABI, abis ...abi.ABI) []ContractEvent {
+	var events []ContractEvent
+	for _, log := range logs {
+		event := ContractEvent{Address: log.Address}
+		if len(log.Topics) > 0 {
+			event.Name = log.Topics[0].Hex()
+		}
+		logABIs := abis
+		if contractABI, ok := registry[log.Address]; ok {
+			logABIs = append([]abi.ABI{contractABI}, abis...)
+		}
+		for _, contractABI := range logABIs {
+			if len(log.Topics) == 0 {
+				break
+			}
+			e, err := contractABI.EventByID(log.Topics[0])
+			if err != nil {
+				continue
+			}
+			values, err := decodeEvent(*e, log)
+			if err != nil {
+				continue
+			}
+			event.Name, event.Values = e.Name, values
+			break
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func decodeEvent(e abi.Event, log *types.Log) ([]ContractValue, error) {
+	nonIndexed, err := e.Inputs.NonIndexed().Unpack(log.Data)
+	if err != nil {
+		return nil, err
+	}
+	var indexed abi.Arguments
+	for _, input := range e.Inputs {
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+	topics := make(map[string]interface{})
+	if err := abi.ParseTopicsIntoMap(topics, indexed, log.Topics[1:]); err != nil {
+		return nil, err
+	}
+
+	var values []ContractValue
+	for _, input := range e.Inputs {
+		var v interface{}
+		if input.Indexed {
+			v = topics[input.Name]
+		} else {
+			v, nonIndexed = nonIndexed[0], nonIndexed[1:]
+		}
+		values = append(values, ContractValue{Name: input.Name, Type: input.Type.String(), Value: formatValue(v)})
+	}
+	return values, nil
+}
@@ -0,0 +1,55 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+func TestParseArgIntRange(t *testing.T) {
+	maxInt255 := new(big.Int).Lsh(big.NewInt(1), 255)
+	tests := []struct {
+		typ   string
+		input string
+		want  string
+		err   bool
+	}{
+		{typ: "int8", input: "127", want: "127"},
+		{typ: "int8", input: "-128", want: "-128"},
+		{typ: "int8", input: "128", err: true},
+		{typ: "int8", input: "-129", err: true},
+		{typ: "int256", input: new(big.Int).Sub(maxInt255, big.NewInt(1)).String(), want: new(big.Int).Sub(maxInt255, big.NewInt(1)).String()},
+		{typ: "int256", input: new(big.Int).Neg(maxInt255).String(), want: new(big.Int).Neg(maxInt255).String()},
+		{typ: "int256", input: maxInt255.String(), err: true},
+		{typ: "int256", input: new(big.Int).Sub(new(big.Int).Neg(maxInt255), big.NewInt(1)).String(), err: true},
+		{typ: "uint8", input: "255", want: "255"},
+		{typ: "uint8", input: "256", err: true},
+		{typ: "uint8", input: "-1", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ+"/"+tt.input, func(t *testing.T) {
+			typ, err := abi.NewType(tt.typ, "", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := parseArg(typ, tt.input)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got:%v", v)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprint(v.Interface()); got != tt.want {
+				t.Fatalf("expected:%v, got:%v", tt.want, got)
+			}
+		})
+	}
+}
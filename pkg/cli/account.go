@@ -3,39 +3,30 @@ package cli
 import (
 	"context"
 	"crypto/ecdsa"
-	"encoding/json"
 	"fmt"
-	"math/big"
-	"os"
 	"strconv"
 	"strings"
 
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
-	"github.com/btcsuite/btcd/chaincfg"
-	big_p "github.com/cryptoriums/packages/big"
 	client_p "github.com/cryptoriums/packages/client"
-	"github.com/cryptoriums/packages/contracts/bindings/interfaces"
 	"github.com/cryptoriums/packages/env"
 	"github.com/cryptoriums/packages/prompt"
 	tx_p "github.com/cryptoriums/packages/tx"
 	"github.com/ethereum/go-ethereum/accounts"
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/params"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/jinzhu/copier"
 	"github.com/pkg/errors"
-	"github.com/tyler-smith/go-bip39"
 )
 
 type AccountImportCmd struct{}
 
 func (self *AccountImportCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
-	_, filePath, err := prompt.ReadFile()
+	filePath, err := cli.FilePath()
 	if err != nil {
 		return err
 	}
@@ -68,12 +59,16 @@ func (self *AccountImportCmd) Run(cli *CLI, ctx context.Context, logger log.Logg
 		})
 	}
 
-	yes, err := prompt.PromptConfirm("Encrypt the imported account?")
-	if err != nil {
-		return errors.Wrap(err, "encrypt accounts prompt")
+	// Non interactive runs always encrypt.
+	encrypt := cli.Yes
+	if !encrypt {
+		encrypt, err = prompt.PromptConfirm("Encrypt the imported account?")
+		if err != nil {
+			return errors.Wrap(err, "encrypt accounts prompt")
+		}
 	}
 
-	if yes {
+	if encrypt {
 		_, pass, err := env.DecryptEnvWithPasswordLoop(e)
 		if err != nil {
 			return errors.Wrap(err, "DecryptEnvWithPasswordLoop")
@@ -93,14 +88,9 @@ func (self *AccountImportCmd) Run(cli *CLI, ctx context.Context, logger log.Logg
 	}
 	e.Accounts = acc
 
-	content, err := json.MarshalIndent(e, "", "    ")
+	err = saveEnv(filePath, e, nil)
 	if err != nil {
-		return errors.Wrap(err, "marshal env")
-	}
-
-	err = os.WriteFile(filePath, content, os.ModePerm)
-	if err != nil {
-		return errors.Wrap(err, "write env to file")
+		return err
 	}
 
 	level.Info(logger).Log("msg", "accounts imported to the env file")
@@ -108,11 +98,14 @@ func (self *AccountImportCmd) Run(cli *CLI, ctx context.Context, logger log.Logg
 
 }
 
-type AccountNewCmd struct{}
+type AccountNewCmd struct {
+	Count int `optional:"" help:"number of accounts, prompted for when not set"`
+	HDFlags
+}
 
 func (self *AccountNewCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
-	var count int
-	for {
+	count := self.Count
+	for count == 0 {
 		_count, err := prompt.PromptInput("How many accounts: ")
 		if err != nil {
 			return errors.Wrap(err, "accounts count prompt")
@@ -130,27 +123,28 @@ func (self *AccountNewCmd) Run(cli *CLI, ctx context.Context, logger log.Logger)
 	}
 
 	var privKeys []*ecdsa.PrivateKey
+	// The derivation path of each key when created from a mnemonic.
+	var paths []string
 
 	if mnemonic != "" {
-		seed := bip39.NewSeed(mnemonic, "") // Here you can choose to pass in the specified password or empty string , Different passwords generate different mnemonics
-
-		master, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+		master, err := self.MasterKey(mnemonic)
 		if err != nil {
-			return errors.Wrap(err, "hdkeychain.NewMaster")
+			return err
 		}
 
-		for i := 0; i < count; i++ {
-			path, err := accounts.ParseDerivationPath("m/44'/60'/" + strconv.Itoa(i) + "'/0/0")
+		for i := self.Start; i < self.Start+uint32(count); i++ {
+			path, err := self.DerivationPath(i)
 			if err != nil {
-				return errors.Wrap(err, "accounts.ParseDerivationPath")
+				return err
 			}
 
-			privateKey, err := derivePrivateKey(master, path)
+			privateKey, err := deriveKey(master, path)
 			if err != nil {
-				return errors.Wrap(err, "derivePrivateKey")
+				return err
 			}
 
 			privKeys = append(privKeys, privateKey)
+			paths = append(paths, path)
 		}
 	} else {
 		for i := 0; i < count; i++ {
@@ -165,7 +159,9 @@ func (self *AccountNewCmd) Run(cli *CLI, ctx context.Context, logger log.Logger)
 	}
 
 	var newAccs []env.Account
-	for _, privKey := range privKeys {
+	var result AccountsResult
+	derivationPaths := make(map[common.Address]string)
+	for i, privKey := range privKeys {
 		publicKeyECDSA, ok := privKey.Public().(*ecdsa.PublicKey)
 		if !ok {
 			return errors.New("failed to cast to public key")
@@ -182,18 +178,143 @@ func (self *AccountNewCmd) Run(cli *CLI, ctx context.Context, logger log.Logger)
 			return errors.Wrap(err, "TestSignMessage")
 		}
 
-		fmt.Println(publicAddr, privateKey)
+		newAcc := NewAccount{Address: publicAddr, Priv: privateKey}
+		if len(paths) > 0 {
+			newAcc.Path = paths[i]
+			derivationPaths[publicAddr] = paths[i]
+		}
+		result.Accounts = append(result.Accounts, newAcc)
+	}
+	err = cli.Print(result)
+	if err != nil {
+		return err
+	}
+
+	yes := cli.Yes
+	if !yes {
+		yes, err = prompt.PromptConfirm("Add to the env file?")
+		if err != nil {
+			return errors.Wrap(err, "prompting for adding accounts to the env file")
+		}
+	}
+	if !yes {
+		return nil
+	}
+	return addAccounts(cli, logger, newAccs, derivationPaths, false)
+}
+
+type AccountDiscoverCmd struct {
+	Tokens []string `optional:"" name:"token" help:"token contract addresses to check the balances of, ETH is always checked"`
+	Gap    uint32   `default:"20" help:"number of consecutive unused accounts after which the scan stops"`
+	HDFlags
+}
+
+func (self *AccountDiscoverCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	if self.Gap == 0 {
+		return errors.New("the gap limit must be at least 1")
+	}
+
+	e, err := cli.Load("enter tags separated by a comma: ")
+	if err != nil {
+		return err
+	}
+
+	client, err := client_p.NewClientCachedNetID(ctx, logger, e.Nodes[0].URL)
+	if err != nil {
+		return errors.Wrap(err, "NewClientCachedNetID")
+	}
 
+	tokens := []Token{{Name: env.ETH_TOKEN.Name, Decimals: ethDecimals}}
+	for _, input := range self.Tokens {
+		token, err := selectToken(ctx, cli, client, client.NetworkID(), input)
+		if err != nil {
+			return errors.Wrap(err, "selectToken")
+		}
+		tokens = append(tokens, token)
 	}
 
-	yes, err := prompt.PromptConfirm("Add to the env file?")
+	mnemonic, err := prompt.PromptInput("Enter mnemonic: ")
 	if err != nil {
-		return errors.Wrap(err, "prompting for adding accounts to the env file")
+		return errors.Wrap(err, "mnemonic prompt")
+	}
+	master, err := self.MasterKey(mnemonic)
+	if err != nil {
+		return err
+	}
+
+	var result DiscoverResult
+	var newAccs []env.Account
+	derivationPaths := make(map[common.Address]string)
+	for i, unused := self.Start, uint32(0); unused < self.Gap; i++ {
+		path, err := self.DerivationPath(i)
+		if err != nil {
+			return err
+		}
+		privKey, err := deriveKey(master, path)
+		if err != nil {
+			return err
+		}
+		addr := crypto.PubkeyToAddress(privKey.PublicKey)
+
+		nonce, err := client.NonceAt(ctx, addr, nil)
+		if err != nil {
+			return errors.Wrap(err, "client.NonceAt")
+		}
+		acc := DiscoveredAccount{Path: path, Address: addr, Nonce: nonce}
+		used := nonce > 0
+		for _, token := range tokens {
+			balance, err := tokenBalance(ctx, client, token, addr)
+			if err != nil {
+				return errors.Wrapf(err, "balance of token:%v", token.Name)
+			}
+			if balance.Sign() > 0 {
+				used = true
+			}
+			acc.Balances = append(acc.Balances, TokenBalance{
+				Token:     token.Name,
+				Raw:       balance.String(),
+				Formatted: FormatAmount(balance, token.Decimals),
+			})
+		}
+		level.Debug(logger).Log("msg", "scanned account", "path", path, "address", addr, "used", used)
+
+		if !used {
+			unused++
+			continue
+		}
+		unused = 0
+
+		result.Accounts = append(result.Accounts, acc)
+		newAccs = append(newAccs, env.Account{Pub: addr, Priv: hexutil.Encode(crypto.FromECDSA(privKey))})
+		derivationPaths[addr] = path
+	}
+
+	err = cli.Print(result)
+	if err != nil {
+		return err
+	}
+	if len(newAccs) == 0 {
+		return nil
+	}
+
+	yes := cli.Yes
+	if !yes {
+		yes, err = prompt.PromptConfirm("Import the used accounts to the env file?")
+		if err != nil {
+			return errors.Wrap(err, "prompting for importing accounts to the env file")
+		}
 	}
 	if !yes {
 		return nil
 	}
-	_, filePath, err := prompt.ReadFile()
+	return addAccounts(cli, logger, newAccs, derivationPaths, false)
+}
+
+// addAccounts adds the accounts to the env file together with their derivation paths.
+// The accounts are encrypted with the env password when encrypt is set
+// or in non interactive runs and otherwise it is prompted for.
+func addAccounts(cli *CLI, logger log.Logger, newAccs []env.Account, derivationPaths map[common.Address]string, encrypt bool) error {
+	filePath, err := cli.FilePath()
 	if err != nil {
 		return err
 	}
@@ -202,11 +323,14 @@ func (self *AccountNewCmd) Run(cli *CLI, ctx context.Context, logger log.Logger)
 		return errors.Wrap(err, "loading env from file")
 	}
 
-	yes, err = prompt.PromptConfirm("Encrypt new accounts?")
-	if err != nil {
-		return errors.Wrap(err, "encrypt accounts prompt")
+	encrypt = encrypt || cli.Yes
+	if !encrypt {
+		encrypt, err = prompt.PromptConfirm("Encrypt new accounts?")
+		if err != nil {
+			return errors.Wrap(err, "encrypt accounts prompt")
+		}
 	}
-	if yes {
+	if encrypt {
 		_, pass, err := env.DecryptEnvWithPasswordLoop(e)
 		if err != nil {
 			return errors.Wrap(err, "DecryptEnvWithPasswordLoop")
@@ -224,14 +348,20 @@ func (self *AccountNewCmd) Run(cli *CLI, ctx context.Context, logger log.Logger)
 	}
 	e.Accounts = acc
 
-	content, err := json.MarshalIndent(e, "", "    ")
+	ext, err := loadEnvExt(filePath)
 	if err != nil {
-		return errors.Wrap(err, "marshal env")
+		return err
+	}
+	if ext.DerivationPaths == nil {
+		ext.DerivationPaths = make(map[common.Address]string)
+	}
+	for addr, path := range derivationPaths {
+		ext.DerivationPaths[addr] = path
 	}
 
-	err = os.WriteFile(filePath, content, os.ModePerm)
+	err = saveEnv(filePath, e, &ext)
 	if err != nil {
-		return errors.Wrap(err, "write env to file")
+		return err
 	}
 
 	level.Info(logger).Log("msg", "new account added to the env file")
@@ -260,75 +390,51 @@ func derivePrivateKey(_masterKey *hdkeychain.ExtendedKey, path accounts.Derivati
 	return privateKeyECDSA, nil
 }
 
-type AccountBalanceCmd struct{}
+type AccountBalanceCmd struct {
+	Token string `optional:"" help:"ETH or token contract address, prompted for when not set"`
+}
 
 func (self *AccountBalanceCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
-	_, filePath, err := prompt.ReadFile()
+	e, err := cli.Load("enter tags separated by a comma: ")
 	if err != nil {
-		return errors.Wrap(err, "prompt.ReadFile")
-	}
-
-	_tags, err := prompt.PromptInput("enter tags separated by a comma: ")
-	if err != nil {
-		return errors.Wrap(err, "prompt tags")
-	}
-	tags := strings.Split(_tags, ",")
-
-	e, err := env.LoadFromFile(filePath, tags...)
-	if err != nil {
-		return errors.Wrap(err, "loading env from file")
+		return err
 	}
 
 	client, err := client_p.NewClientCachedNetID(ctx, logger, e.Nodes[0].URL)
 	if err != nil {
 		return errors.Wrap(err, "NewClientCachedNetID")
 	}
-	token, err := prompt.Token(client.NetworkID())
+	token, err := selectToken(ctx, cli, client, client.NetworkID(), self.Token)
 	if err != nil {
 		return errors.Wrap(err, "selectToken")
 	}
-	erc20I, err := interfaces.NewIERC20(token.Address[client.NetworkID()], client)
-	if err != nil {
-		return errors.Wrap(err, "NewIERC20")
-	}
-	fmt.Println("Token:" + token.Name)
-	fmt.Println("Accounts")
-	for i, account := range e.Accounts {
-		var balance *big.Int
-
-		if token.Name == env.ETH_TOKEN.Name {
-			balance, err = client.BalanceAt(ctx, account.Pub, nil)
-			if err != nil {
-				return errors.Wrap(err, "client.BalanceAt")
-			}
-		} else {
-			balance, err = erc20I.BalanceOf(&bind.CallOpts{Context: ctx}, account.Pub)
-			if err != nil {
-				return errors.Wrap(err, "erc20I.BalanceOf")
-			}
+	result := BalancesResult{Token: token.Name}
+	for _, account := range e.Accounts {
+		balance, err := tokenBalance(ctx, client, token, account.Pub)
+		if err != nil {
+			return err
 		}
-		fmt.Println(strconv.Itoa(i) + ": " + account.Pub.Hex() + " " + fmt.Sprintf("%.6f", big_p.ToFloatDiv(balance, params.Ether)) + " " + strings.Join(account.Tags, ","))
-
+		result.Accounts = append(result.Accounts, Balance{
+			Address:   account.Pub,
+			Tags:      account.Tags,
+			Raw:       balance.String(),
+			Formatted: FormatAmount(balance, token.Decimals),
+		})
 	}
 
-	fmt.Println("Contracts")
-	for i, contract := range e.Contracts {
-		var balance *big.Int
-		if token.Name == env.ETH_TOKEN.Name {
-			balance, err = client.BalanceAt(ctx, contract.Address, nil)
-			if err != nil {
-				return errors.Wrap(err, "client.BalanceAt")
-			}
-		} else {
-			balance, err = erc20I.BalanceOf(&bind.CallOpts{Context: ctx}, contract.Address)
-			if err != nil {
-				return errors.Wrap(err, "erc20I.BalanceOf")
-			}
+	for _, contract := range e.Contracts {
+		balance, err := tokenBalance(ctx, client, token, contract.Address)
+		if err != nil {
+			return err
 		}
-
-		fmt.Println(strconv.Itoa(i) + ": " + contract.Address.Hex() + " " + fmt.Sprintf("%.6f", big_p.ToFloatDiv(balance, params.Ether)) + " " + strings.Join(contract.Tags, ","))
+		result.Contracts = append(result.Contracts, Balance{
+			Address:   contract.Address,
+			Tags:      contract.Tags,
+			Raw:       balance.String(),
+			Formatted: FormatAmount(balance, token.Decimals),
+		})
 	}
-	return nil
+	return cli.Print(result)
 }
 
 func DedupAccounts(accs []env.Account) ([]env.Account, map[common.Address]bool) {
@@ -0,0 +1,263 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	client_p "github.com/cryptoriums/packages/client"
+	"github.com/cryptoriums/packages/prompt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+var approvalTopic = crypto.Keccak256Hash([]byte("Approval(address,address,uint256)"))
+
+// unlimitedAllowance is the allowance from which an approval is considered unlimited.
+// Some tokens like UNI cap the allowances to uint96 so the max uint256 isn't used.
+var unlimitedAllowance = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 96), big.NewInt(1))
+
+type TokenAllowancesCmd struct {
+	Tokens   []string `optional:"" name:"token" help:"token addresses to check, all tokens with approvals when not set"`
+	Spenders []string `optional:"" name:"spender" help:"spender addresses to check instead of scanning the Approval events, requires --token"`
+	Revoke   string   `optional:"" help:"numbers of the listed allowances to revoke separated by a comma or all, prompted for in interactive runs"`
+	LogsFlags
+	Gas
+}
+
+// allowanceKey is an owner approval of a token spender.
+type allowanceKey struct {
+	owner   common.Address
+	token   common.Address
+	spender common.Address
+}
+
+func (self *TokenAllowancesCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	e, err := cli.Load("enter tags separated by a comma: ")
+	if err != nil {
+		return err
+	}
+	if len(e.Accounts) == 0 {
+		return errors.New("no env accounts with the selected tags")
+	}
+
+	client, err := client_p.NewClientCachedNetID(ctx, logger, e.Nodes[0].URL)
+	if err != nil {
+		return errors.Wrap(err, "NewClientCachedNetID")
+	}
+
+	var owners []common.Address
+	for _, acc := range e.Accounts {
+		owners = append(owners, acc.Pub)
+	}
+	var tokenAddrs []common.Address
+	for _, input := range self.Tokens {
+		addr, err := parseAddress(input)
+		if err != nil {
+			return errors.Wrap(err, "token")
+		}
+		tokenAddrs = append(tokenAddrs, addr)
+	}
+
+	var keys []allowanceKey
+	if len(self.Spenders) > 0 {
+		if len(tokenAddrs) == 0 {
+			return errors.New("checking spenders requires --token")
+		}
+		for _, input := range self.Spenders {
+			spender, err := parseAddress(input)
+			if err != nil {
+				return errors.Wrap(err, "spender")
+			}
+			for _, owner := range owners {
+				for _, token := range tokenAddrs {
+					keys = append(keys, allowanceKey{owner: owner, token: token, spender: spender})
+				}
+			}
+		}
+	} else {
+		keys, err = self.scanApprovals(ctx, client, owners, tokenAddrs)
+		if err != nil {
+			return err
+		}
+	}
+
+	tokens := make(map[common.Address]Token)
+	// Tokens that fail are skipped so that the other allowances are still listed.
+	failed := make(map[common.Address]bool)
+	var result AllowancesResult
+	var outstanding []allowanceKey
+	for _, key := range keys {
+		if failed[key.token] {
+			continue
+		}
+		values, err := callMethod(ctx, client, key.token, "allowance(address,address) returns (uint256)", key.owner, key.spender)
+		if err != nil {
+			level.Warn(logger).Log("msg", "skipping the token allowances", "token", key.token, "err", err)
+			failed[key.token] = true
+			continue
+		}
+		amount := values[0].(*big.Int)
+		if amount.Sign() == 0 {
+			continue
+		}
+		token, ok := tokens[key.token]
+		if !ok {
+			token, err = selectToken(ctx, cli, client, client.NetworkID(), key.token.Hex())
+			if err != nil {
+				level.Warn(logger).Log("msg", "skipping the token allowances", "token", key.token, "err", err)
+				failed[key.token] = true
+				continue
+			}
+			tokens[key.token] = token
+		}
+		outstanding = append(outstanding, key)
+		result.Allowances = append(result.Allowances, Allowance{
+			Number:    len(outstanding),
+			Owner:     key.owner,
+			Token:     key.token,
+			TokenName: token.Name,
+			Spender:   key.spender,
+			Amount:    FormatAmount(amount, token.Decimals),
+			Unlimited: amount.Cmp(unlimitedAllowance) >= 0,
+		})
+	}
+	err = cli.Print(result)
+	if err != nil {
+		return err
+	}
+	if len(outstanding) == 0 {
+		return nil
+	}
+
+	selected, err := self.selectRevokes(cli.Yes, len(outstanding))
+	if err != nil {
+		return err
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+
+	fees, err := self.Fees(ctx, client, cli.Yes)
+	if err != nil {
+		return err
+	}
+
+	nonces := make(map[common.Address]uint64)
+	var txs []batchTx
+	for _, i := range selected {
+		key := outstanding[i]
+		from, err := findAccount(e.Accounts, key.owner.Hex())
+		if err != nil {
+			return err
+		}
+		nonce, ok := nonces[key.owner]
+		if !ok {
+			nonce, err = client.PendingNonceAt(ctx, key.owner)
+			if err != nil {
+				return errors.Wrap(err, "PendingNonceAt")
+			}
+		}
+		data, err := packMethod("approve(address,uint256)", key.spender, big.NewInt(0))
+		if err != nil {
+			return err
+		}
+		action := fmt.Sprintf("revoke of:%v from:%v, spender:%v", tokens[key.token].Name, key.owner, key.spender)
+		unsigned, err := self.buildTx(ctx, cli, client, key.owner, nonce, fees, key.token, nil, data, action)
+		if err != nil {
+			return errors.Wrapf(err, "allowance %v", i+1)
+		}
+		nonces[key.owner] = nonce + 1
+		txs = append(txs, batchTx{from: from, token: tokens[key.token], amount: big.NewInt(0), unsigned: unsigned})
+	}
+
+	err = cli.Confirm(fmt.Sprintf("Confirm %v revokes, %v", len(txs), fees))
+	if err != nil {
+		return err
+	}
+
+	// Batches are always tracked until all TXs are mined.
+	self.Wait = true
+	return self.sendBatch(ctx, cli, logger, client, e.Accounts, txs)
+}
+
+// scanApprovals returns the token spenders from the Approval events of the owners.
+func (self *TokenAllowancesCmd) scanApprovals(ctx context.Context, client client_p.EthClient, owners []common.Address, tokens []common.Address) ([]allowanceKey, error) {
+	logs, err := self.FilterLogs(ctx, client, ethereum.FilterQuery{
+		Addresses: tokens,
+		Topics:    [][]common.Hash{{approvalTopic}, addressTopics(owners)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[allowanceKey]bool)
+	var keys []allowanceKey
+	for _, log := range logs {
+		// ERC721 approvals have the same event ID with an indexed token ID.
+		if len(log.Topics) != 3 {
+			continue
+		}
+		key := allowanceKey{
+			owner:   common.BytesToAddress(log.Topics[1].Bytes()),
+			token:   log.Address,
+			spender: common.BytesToAddress(log.Topics[2].Bytes()),
+		}
+		if !found[key] {
+			found[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// selectRevokes returns the indexes of the allowances to revoke.
+func (self *TokenAllowancesCmd) selectRevokes(yes bool, count int) ([]int, error) {
+	input := self.Revoke
+	if input == "" {
+		if yes {
+			return nil, nil
+		}
+		var err error
+		input, err = prompt.PromptInput("Enter numbers of the allowances to revoke separated by a comma, all or leave empty to exit: ")
+		if err != nil {
+			return nil, errors.Wrap(err, "revoke prompt")
+		}
+	}
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil, nil
+	}
+
+	var selected []int
+	if strings.EqualFold(input, "all") {
+		for i := 0; i < count; i++ {
+			selected = append(selected, i)
+		}
+		return selected, nil
+	}
+	seen := make(map[int]bool)
+	for _, item := range strings.Split(input, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || n < 1 || n > count {
+			return nil, errors.Errorf("invalid allowance number:%v", item)
+		}
+		if !seen[n-1] {
+			seen[n-1] = true
+			selected = append(selected, n-1)
+		}
+	}
+	// Keep the nonces of each owner in the listed order.
+	sort.Ints(selected)
+	return selected, nil
+}
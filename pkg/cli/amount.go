@@ -0,0 +1,179 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	"github.com/cryptoriums/packages/prompt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+const ethDecimals = 18
+
+// maxUint256 is the type(uint256).max approval that most tokens never decrease.
+var maxUint256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+const erc20MetadataABI = `[
+	{"name":"name","type":"function","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"name":"symbol","type":"function","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"name":"decimals","type":"function","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint8"}]}
+]`
+
+// ParseAmount converts a decimal string like "1.5" to base units of a token with the given decimals.
+// The conversion is exact and amounts with more fractional digits than the token decimals are rejected.
+func ParseAmount(amount string, decimals uint8) (*big.Int, error) {
+	amount = strings.TrimSpace(amount)
+	if amount == "" {
+		return nil, errors.New("empty amount")
+	}
+	whole, frac, hasFrac := strings.Cut(amount, ".")
+	if hasFrac && frac == "" && whole == "" {
+		return nil, errors.Errorf("invalid amount:%v", amount)
+	}
+	if len(frac) > int(decimals) {
+		return nil, errors.Errorf("amount:%v has more than %v decimals", amount, decimals)
+	}
+	if strings.Trim(whole+frac, "0123456789") != "" {
+		return nil, errors.Errorf("invalid amount:%v", amount)
+	}
+	digits := strings.TrimLeft(whole+frac+strings.Repeat("0", int(decimals)-len(frac)), "0")
+	if digits == "" {
+		return big.NewInt(0), nil
+	}
+	v, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, errors.Errorf("invalid amount:%v", amount)
+	}
+	return v, nil
+}
+
+// FormatAmount converts base units of a token with the given decimals to a decimal string without trailing zeros.
+func FormatAmount(amount *big.Int, decimals uint8) string {
+	if amount == nil {
+		return "0"
+	}
+	sign := ""
+	if amount.Sign() < 0 {
+		sign = "-"
+	}
+	digits := new(big.Int).Abs(amount).String()
+	if len(digits) <= int(decimals) {
+		digits = strings.Repeat("0", int(decimals)-len(digits)+1) + digits
+	}
+	whole, frac := digits[:len(digits)-int(decimals)], strings.TrimRight(digits[len(digits)-int(decimals):], "0")
+	if frac == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + frac
+}
+
+// tokenDecimals reads the token decimals from the contract.
+func tokenDecimals(ctx context.Context, client bind.ContractCaller, token common.Address) (uint8, error) {
+	var decimals uint8
+	if err := callTokenMetadata(ctx, client, token, "decimals", &decimals); err != nil {
+		return 0, err
+	}
+	return decimals, nil
+}
+
+func callTokenMetadata(ctx context.Context, client bind.ContractCaller, token common.Address, method string, result interface{}) error {
+	metaABI, err := abi.JSON(strings.NewReader(erc20MetadataABI))
+	if err != nil {
+		return errors.Wrap(err, "parse erc20 metadata abi")
+	}
+	input, err := metaABI.Pack(method)
+	if err != nil {
+		return errors.Wrapf(err, "pack %v", method)
+	}
+	output, err := client.CallContract(ctx, ethereum.CallMsg{To: &token, Data: input}, nil)
+	if err != nil {
+		return errors.Wrapf(err, "call %v", method)
+	}
+	if err := metaABI.UnpackIntoInterface(result, method, output); err != nil {
+		return errors.Wrapf(err, "unpack %v", method)
+	}
+	return nil
+}
+
+// selectAmount parses the amount in token units to base units or prompts for it when the input is empty.
+func selectAmount(logger log.Logger, input string, token Token, msg string) (*big.Int, error) {
+	if input != "" {
+		return ParseAmount(input, token.Decimals)
+	}
+	for {
+		_amount, err := prompt.PromptInput(msg)
+		if err != nil {
+			return nil, errors.Wrap(err, "select amount prompt")
+		}
+		amount, err := ParseAmount(_amount, token.Decimals)
+		if err != nil {
+			level.Error(logger).Log("msg", "parsing amount", "err", err)
+			continue
+		}
+		return amount, nil
+	}
+}
+
+// parseApproveAmount parses an approve limit in token units, in base units when raw is set
+// or the max and unlimited keywords which return the given max.
+func parseApproveAmount(input string, raw bool, decimals uint8, max *big.Int) (*big.Int, error) {
+	input = strings.TrimSpace(input)
+	if strings.EqualFold(input, "max") || strings.EqualFold(input, "unlimited") {
+		return new(big.Int).Set(max), nil
+	}
+	var amount *big.Int
+	if raw {
+		var ok bool
+		amount, ok = new(big.Int).SetString(input, 10)
+		if !ok || amount.Sign() < 0 {
+			return nil, errors.Errorf("invalid base units amount:%v", input)
+		}
+	} else {
+		var err error
+		amount, err = ParseAmount(input, decimals)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if amount.Cmp(max) > 0 {
+		return nil, errors.Errorf("amount:%v is above the max:%v", input, max)
+	}
+	return amount, nil
+}
+
+// selectApproveAmount parses the approve limit or prompts for it when the input is empty.
+func selectApproveAmount(logger log.Logger, input string, raw bool, token Token, msg string, max *big.Int) (*big.Int, error) {
+	if input != "" {
+		return parseApproveAmount(input, raw, token.Decimals, max)
+	}
+	for {
+		_amount, err := prompt.PromptInput(msg)
+		if err != nil {
+			return nil, errors.Wrap(err, "select amount prompt")
+		}
+		amount, err := parseApproveAmount(_amount, raw, token.Decimals, max)
+		if err != nil {
+			level.Error(logger).Log("msg", "parsing amount", "err", err)
+			continue
+		}
+		return amount, nil
+	}
+}
+
+// formatApproveAmount formats the approve limit in token units or as unlimited for the max.
+func formatApproveAmount(amount *big.Int, decimals uint8, max *big.Int) string {
+	if amount.Cmp(max) == 0 {
+		return "unlimited"
+	}
+	return FormatAmount(amount, decimals)
+}
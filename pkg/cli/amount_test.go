@@ -0,0 +1,122 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		input    string
+		decimals uint8
+		want     string
+		err      bool
+	}{
+		{input: "1.5", decimals: 18, want: "1500000000000000000"},
+		{input: "0.000000000000000001", decimals: 18, want: "1"},
+		{input: ".5", decimals: 6, want: "500000"},
+		{input: "5.", decimals: 6, want: "5000000"},
+		{input: " 007 ", decimals: 0, want: "7"},
+		{input: "0", decimals: 18, want: "0"},
+		// No rounding, the extra digits are rejected even when they are zeros.
+		{input: "0.0000000000000000001", decimals: 18, err: true},
+		{input: "1.10", decimals: 1, err: true},
+		{input: "1.5", decimals: 0, err: true},
+		{input: "-1", decimals: 18, err: true},
+		{input: "1e18", decimals: 18, err: true},
+		{input: ".", decimals: 18, err: true},
+		{input: "", decimals: 18, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseAmount(tt.input, tt.decimals)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got:%v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Fatalf("expected:%v, got:%v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		amount   string
+		decimals uint8
+		want     string
+	}{
+		{amount: "1500000000000000000", decimals: 18, want: "1.5"},
+		{amount: "1", decimals: 18, want: "0.000000000000000001"},
+		{amount: "1000000", decimals: 6, want: "1"},
+		{amount: "0", decimals: 18, want: "0"},
+		{amount: "-1500000", decimals: 6, want: "-1.5"},
+		{amount: "123", decimals: 0, want: "123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.amount, func(t *testing.T) {
+			amount, _ := new(big.Int).SetString(tt.amount, 10)
+			got := FormatAmount(amount, tt.decimals)
+			if got != tt.want {
+				t.Fatalf("expected:%v, got:%v", tt.want, got)
+			}
+			// The formatted amounts parse back exactly.
+			if amount.Sign() >= 0 {
+				parsed, err := ParseAmount(got, tt.decimals)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if parsed.Cmp(amount) != 0 {
+					t.Fatalf("round trip expected:%v, got:%v", amount, parsed)
+				}
+			}
+		})
+	}
+}
+
+func TestParseApproveAmount(t *testing.T) {
+	max := big.NewInt(1000000)
+	tests := []struct {
+		input string
+		raw   bool
+		want  string
+		err   bool
+	}{
+		{input: "max", want: "1000000"},
+		{input: "Unlimited", want: "1000000"},
+		{input: "0.5", want: "500000"},
+		{input: "500", raw: true, want: "500"},
+		{input: "1.0000001", err: true},
+		{input: "2", err: true},
+		{input: "1000001", raw: true, err: true},
+		{input: "1.5", raw: true, err: true},
+		{input: "-1", raw: true, err: true},
+		{input: "-1", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseApproveAmount(tt.input, tt.raw, 6, max)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got:%v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Fatalf("expected:%v, got:%v", tt.want, got)
+			}
+		})
+	}
+}
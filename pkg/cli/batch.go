@@ -0,0 +1,344 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	client_p "github.com/cryptoriums/packages/client"
+	"github.com/cryptoriums/packages/contracts/bindings/interfaces"
+	"github.com/cryptoriums/packages/env"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// BatchTransfer is a row of a batch transfer file.
+type BatchTransfer struct {
+	// From is the sender address or a tag that matches a single env account.
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Token  string `json:"token"`
+	Amount string `json:"amount"`
+}
+
+type TokenBatchTransferCmd struct {
+	File string `arg:"" type:"existingfile" help:"CSV file with from,to,token,amount columns or a JSON array of objects with the same fields, from is an address or a tag of an env account"`
+	Gas
+}
+
+type batchTx struct {
+	from     env.Account
+	token    Token
+	amount   *big.Int
+	unsigned UnsignedTx
+}
+
+func (self *TokenBatchTransferCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	rows, err := readBatchTransfers(self.File)
+	if err != nil {
+		return err
+	}
+
+	e, err := cli.Load("enter tags separated by a comma: ")
+	if err != nil {
+		return err
+	}
+
+	client, err := client_p.NewClientCachedNetID(ctx, logger, e.Nodes[0].URL)
+	if err != nil {
+		return errors.Wrap(err, "NewClientCachedNetID")
+	}
+
+	fees, err := self.Fees(ctx, client, cli.Yes)
+	if err != nil {
+		return err
+	}
+
+	// Validate all rows before sending anything.
+	var errs []string
+	tokens := make(map[string]Token)
+	nonces := make(map[common.Address]uint64)
+	var txs []batchTx
+	for i, row := range rows {
+		tx, err := self.buildBatchTx(ctx, cli, client, e.Accounts, tokens, nonces, fees, row)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("row %v: %v", i+1, err))
+			continue
+		}
+		txs = append(txs, tx)
+	}
+	if len(errs) > 0 {
+		return errors.Errorf("invalid batch transfers:\n%v", strings.Join(errs, "\n"))
+	}
+
+	summary, err := batchSummary(ctx, client, txs)
+	if err != nil {
+		return err
+	}
+	err = cli.Print(summary)
+	if err != nil {
+		return err
+	}
+	for _, total := range summary.Totals {
+		if total.Insufficient {
+			return errors.Errorf("insufficient %v balance of:%v", total.Token, total.From)
+		}
+	}
+
+	err = cli.Confirm(fmt.Sprintf("Confirm %v transfers, %v", len(txs), fees))
+	if err != nil {
+		return err
+	}
+
+	// Batches are always tracked until all TXs are mined.
+	self.Wait = true
+	return self.sendBatch(ctx, cli, logger, client, e.Accounts, txs)
+}
+
+// sendBatch decrypts the senders keys and sends all TXs
+// in order so that the nonces of each sender are sequential.
+func (self *Gas) sendBatch(ctx context.Context, cli *CLI, logger log.Logger, client txBackend, accs []env.Account, txs []batchTx) error {
+	senders := make(map[common.Address]env.Account)
+	for _, tx := range txs {
+		if _, ok := senders[tx.from.Pub]; ok {
+			continue
+		}
+		sender, _, err := selectAccountAndDecrypt(accs, tx.from.Pub.Hex(), false, "")
+		if err != nil {
+			return errors.Wrap(err, "decrypt sender")
+		}
+		senders[tx.from.Pub] = sender
+	}
+
+	var sent []*types.Transaction
+	for _, tx := range txs {
+		signed, err := signAndSend(ctx, client, senders[tx.from.Pub], tx.unsigned)
+		if err != nil {
+			// The nonces of the next TXs of the sender depend on this one.
+			level.Error(logger).Log("msg", "sending batch stopped", "sent", len(sent), "total", len(txs))
+			return errors.Wrapf(err, "send %v", tx.unsigned.Description)
+		}
+		sent = append(sent, signed)
+		err = cli.Print(TxResult{Hash: signed.Hash(), Nonce: signed.Nonce(), From: tx.from.Pub})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !self.Wait {
+		return nil
+	}
+	var reverted, failed int
+	for i, tx := range sent {
+		_, err := self.WaitReceipt(ctx, cli, client, tx, txs[i].from.Pub)
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrReverted):
+			reverted++
+		default:
+			failed++
+			if err := cli.Print(ErrorResult{Error: err.Error()}); err != nil {
+				return err
+			}
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("%v of %v TXs weren't mined", failed, len(sent))
+	}
+	if reverted > 0 {
+		return errors.Wrapf(ErrReverted, "%v of %v TXs", reverted, len(sent))
+	}
+	return nil
+}
+
+// buildBatchTx validates the row, simulates the transfer
+// and assigns it the next nonce of the sender.
+func (self *TokenBatchTransferCmd) buildBatchTx(
+	ctx context.Context,
+	cli *CLI,
+	client txBackend,
+	accs []env.Account,
+	tokens map[string]Token,
+	nonces map[common.Address]uint64,
+	fees Fees,
+	row BatchTransfer,
+) (batchTx, error) {
+	from, err := batchSender(accs, row.From)
+	if err != nil {
+		return batchTx{}, err
+	}
+	receiver, err := parseAddress(strings.TrimSpace(row.To))
+	if err != nil {
+		return batchTx{}, errors.Wrap(err, "receiver")
+	}
+	if strings.TrimSpace(row.Token) == "" {
+		return batchTx{}, errors.New("empty token")
+	}
+
+	tokenKey := strings.ToLower(strings.TrimSpace(row.Token))
+	token, ok := tokens[tokenKey]
+	if !ok {
+		token, err = selectToken(ctx, cli, client, client.NetworkID(), strings.TrimSpace(row.Token))
+		if err != nil {
+			return batchTx{}, errors.Wrap(err, "token")
+		}
+		tokens[tokenKey] = token
+	}
+
+	amount, err := ParseAmount(row.Amount, token.Decimals)
+	if err != nil {
+		return batchTx{}, err
+	}
+	if amount.Sign() == 0 {
+		return batchTx{}, errors.New("zero amount")
+	}
+
+	nonce, ok := nonces[from.Pub]
+	if !ok {
+		nonce, err = client.PendingNonceAt(ctx, from.Pub)
+		if err != nil {
+			return batchTx{}, errors.Wrap(err, "PendingNonceAt")
+		}
+	}
+
+	to, value := receiver, amount
+	var data []byte
+	if !token.IsETH() {
+		to, value = token.Address, nil
+		erc20I, err := interfaces.NewIERC20(token.Address, client)
+		if err != nil {
+			return batchTx{}, errors.Wrap(err, "NewIERC20")
+		}
+		data, err = packCall(func(opts *bind.TransactOpts) (*types.Transaction, error) {
+			return erc20I.Transfer(opts, receiver, amount)
+		})
+		if err != nil {
+			return batchTx{}, errors.Wrap(err, "pack Transfer")
+		}
+	}
+
+	action := fmt.Sprintf("transfer of:%v from:%v, to:%v, amount:%v", token.Name, from.Pub, receiver, FormatAmount(amount, token.Decimals))
+	unsigned, err := self.buildTx(ctx, cli, client, from.Pub, nonce, fees, to, value, data, action)
+	if err != nil {
+		return batchTx{}, err
+	}
+	nonces[from.Pub] = nonce + 1
+
+	return batchTx{from: from, token: token, amount: amount, unsigned: unsigned}, nil
+}
+
+// batchSender returns the env account with the address or the only account with the tag.
+func batchSender(accs []env.Account, input string) (env.Account, error) {
+	input = strings.TrimSpace(input)
+	if common.IsHexAddress(input) {
+		return findAccount(accs, input)
+	}
+	var matches []env.Account
+	for _, acc := range accs {
+		for _, tag := range acc.Tags {
+			if tag == input {
+				matches = append(matches, acc)
+				break
+			}
+		}
+	}
+	if len(matches) != 1 {
+		return env.Account{}, errors.Errorf("sender tag:%v matches %v accounts instead of one", input, len(matches))
+	}
+	return matches[0], nil
+}
+
+// batchSummary sums the amounts and the max TX fees per sender and token
+// and compares them with the sender balances.
+func batchSummary(ctx context.Context, client balanceReader, txs []batchTx) (BatchSummaryResult, error) {
+	type key struct {
+		from  common.Address
+		token string
+	}
+	var order []key
+	totals := make(map[key]*BatchTotal)
+	amounts := make(map[key]*big.Int)
+	tokens := make(map[key]Token)
+	add := func(from common.Address, token Token, amount *big.Int) {
+		k := key{from: from, token: token.Name}
+		if _, ok := totals[k]; !ok {
+			order = append(order, k)
+			totals[k] = &BatchTotal{From: from, Token: token.Name}
+			amounts[k] = big.NewInt(0)
+			tokens[k] = token
+		}
+		amounts[k].Add(amounts[k], amount)
+	}
+
+	eth := Token{Name: env.ETH_TOKEN.Name, Decimals: ethDecimals}
+	for _, tx := range txs {
+		add(tx.from.Pub, tx.token, tx.amount)
+		add(tx.from.Pub, eth, maxTxFee(tx.unsigned))
+		totals[key{from: tx.from.Pub, token: tx.token.Name}].Count++
+	}
+
+	var result BatchSummaryResult
+	for _, k := range order {
+		balance, err := tokenBalance(ctx, client, tokens[k], k.from)
+		if err != nil {
+			return BatchSummaryResult{}, errors.Wrapf(err, "balance of:%v token:%v", k.from, k.token)
+		}
+		total := totals[k]
+		total.Amount = FormatAmount(amounts[k], tokens[k].Decimals)
+		total.Balance = FormatAmount(balance, tokens[k].Decimals)
+		total.Insufficient = balance.Cmp(amounts[k]) < 0
+		result.Totals = append(result.Totals, *total)
+	}
+	return result, nil
+}
+
+func readBatchTransfers(file string) ([]BatchTransfer, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "open batch file")
+	}
+	defer f.Close()
+
+	var rows []BatchTransfer
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		if err := json.NewDecoder(f).Decode(&rows); err != nil {
+			return nil, errors.Wrap(err, "decode batch json")
+		}
+	} else {
+		r := csv.NewReader(f)
+		r.FieldsPerRecord = 4
+		r.TrimLeadingSpace = true
+		r.Comment = '#'
+		for {
+			record, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, errors.Wrap(err, "read batch csv")
+			}
+			// Skip the optional header.
+			if len(rows) == 0 && strings.EqualFold(record[0], "from") {
+				continue
+			}
+			rows = append(rows, BatchTransfer{From: record[0], To: record[1], Token: record[2], Amount: record[3]})
+		}
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("no transfers in the batch file")
+	}
+	return rows, nil
+}
@@ -0,0 +1,72 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadBatchTransfers(t *testing.T) {
+	first := BatchTransfer{From: "alice", To: "0x00000000000000000000000000000000000000aa", Token: "ETH", Amount: "1.5"}
+	second := BatchTransfer{From: "0x00000000000000000000000000000000000000bb", To: "0x00000000000000000000000000000000000000cc", Token: "USDC", Amount: "10"}
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []BatchTransfer
+		err     bool
+	}{
+		{
+			name: "csv",
+			file: "batch.csv",
+			content: "alice,0x00000000000000000000000000000000000000aa,ETH,1.5\n" +
+				"0x00000000000000000000000000000000000000bb,0x00000000000000000000000000000000000000cc,USDC,10\n",
+			want: []BatchTransfer{first, second},
+		},
+		{
+			name: "csv header comments and spaces",
+			file: "batch.csv",
+			content: "from,to,token,amount\n" +
+				"# payroll\n" +
+				"alice, 0x00000000000000000000000000000000000000aa, ETH, 1.5\n",
+			want: []BatchTransfer{first},
+		},
+		{
+			name: "json",
+			file: "batch.JSON",
+			content: `[{"from":"alice","to":"0x00000000000000000000000000000000000000aa","token":"ETH","amount":"1.5"},
+				{"from":"0x00000000000000000000000000000000000000bb","to":"0x00000000000000000000000000000000000000cc","token":"USDC","amount":"10"}]`,
+			want: []BatchTransfer{first, second},
+		},
+		{name: "csv missing column", file: "batch.csv", content: "alice,0x00000000000000000000000000000000000000aa,1.5\n", err: true},
+		{name: "csv only header", file: "batch.csv", content: "from,to,token,amount\n", err: true},
+		{name: "empty json", file: "batch.json", content: "[]", err: true},
+		{name: "invalid json", file: "batch.json", content: "{", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(file, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readBatchTransfers(file)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got:%v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got:%v, want:%v", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,178 +5,60 @@ package cli
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"math/big"
-	"math/rand"
-	"os"
 	"strings"
-	"time"
 
-	big_p "github.com/cryptoriums/packages/big"
-	client_p "github.com/cryptoriums/packages/client"
 	"github.com/cryptoriums/packages/env"
 	"github.com/cryptoriums/packages/prompt"
-	tx_p "github.com/cryptoriums/packages/tx"
-	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/params"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
-	"github.com/tyler-smith/go-bip39"
 	"github.com/willabides/kongplete"
 )
 
 var CLIInstance CLI
 
-type Gas struct {
-	GasPrice float64 `optional:"" help:"gas max fee to use when running the command"`
-}
-
-func (self *Gas) Validate() error {
-	if self.GasPrice > 300 {
-		confirmed, err := prompt.PromptConfirm(fmt.Sprintf("confirm high gas fee:%v", self.GasPrice))
-		if err != nil || !confirmed {
-			return errors.New("canceled")
-		}
-	}
-	return nil
-}
-
 type CLI struct {
+	EnvFlags
+	Yes    bool   `short:"y" env:"WALLGER_YES" help:"answer yes to all confirmations and run commands only once"`
+	Output string `short:"o" enum:"text,table,json" default:"text" env:"WALLGER_OUTPUT" help:"output format of the command results: text, table or json"`
+
 	Mnemonic           MnemonicCmd                  `cmd:"" help:"Generate a new mnemonic"`
-	CancelTx           CancelTxCmd                  `cmd:"" help:"Cancel a pending TX"`
+	Tx                 TxCmd                        `cmd:"" help:"TX commands"`
 	Env                EnvCmd                       `cmd:"" help:"Env commands"`
 	Encrypt            EncryptCmd                   `cmd:"" help:"Encrypts a string"`
 	Decrypt            DecryptCmd                   `cmd:"" help:"Decrypts a string"`
 	Token              TokenCmd                     `cmd:"" help:"token commands"`
+	Nft                NftCmd                       `cmd:"" help:"ERC721 and ERC1155 commands"`
 	SetOwner           SetOwnerCmd                  `cmd:"" help:"set a new owner of a contract"`
+	Contract           ContractCmd                  `cmd:"" help:"call any contract method by its ABI"`
 	Account            AccountCmd                   `cmd:"" help:"account management"`
+	Sign               SignCmd                      `cmd:"" help:"sign messages and typed data with an env account"`
+	Verify             VerifyCmd                    `cmd:"" help:"recover the signer of a message or typed data signature"`
 	InstallCompletions kongplete.InstallCompletions `cmd:"" help:"install shell completions"`
 }
 
-type CancelTxCmd struct{}
-
-func (self *CancelTxCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
-	_, filePath, err := prompt.ReadFile()
-	if err != nil {
-		return err
-	}
-	_tags, err := prompt.PromptInput("enter tags separated by a comma: ")
-	if err != nil {
-		return errors.Wrap(err, "prompt tags")
-	}
-	tags := strings.Split(_tags, ",")
-
-	e, err := env.LoadFromFile(filePath, tags...)
-	if err != nil {
-		return errors.Wrap(err, "loading env from file")
-	}
-
-	client, err := client_p.NewClientCachedNetID(ctx, logger, e.Nodes[0].URL)
-	if err != nil {
-		return errors.Wrap(err, "NewClientCachedNetID")
-	}
-
-	hash, err := prompt.PromptInput("TX hash to cancel: ")
-	if err != nil {
-		return errors.Wrap(err, "TX hash input prompt")
-	}
-
-	tx, isPending, err := client.TransactionByHash(ctx, common.HexToHash(hash))
-	if err != nil {
-		return errors.Wrap(err, "TransactionByHash")
-	}
-	if !isPending {
-		return errors.New("TX is not in pending state")
-	}
-
-	signer := types.LatestSignerForChainID(big.NewInt(client.NetworkID()))
-
-	sender, err := signer.Sender(tx)
-	if err != nil {
-		return errors.Wrap(err, "signer.Sender")
-	}
-
-	var acc tx_p.Account
-	for _, a := range e.Accounts {
-		if a.Pub.Hex() == sender.Hex() {
-			if env.IsEncrypted(a.Priv) {
-				a.Priv, _, err = env.DecryptWithPasswordLoop(a.Priv)
-				if err != nil {
-					return errors.Wrap(err, "DecryptWithPasswordLoop")
-				}
-			}
-
-			acc, err = tx_p.AccountFromPrvKey(a.Priv)
-			if err != nil {
-				return errors.Wrap(err, "AccountFromPrvKey")
-			}
-			break
-		}
-	}
-
-	gasPrice := big_p.ToFloatDiv(tx.GasPrice(), params.GWei)
-
-	nonce, err := client.NonceAt(ctx, acc.PublicKey, nil)
-	if err != nil {
-		return errors.Wrap(err, "NonceAt")
-	}
-	tx, _, err = tx_p.NewSignedTX(
-		ctx,
-		acc.PrivateKey,
-		acc.PublicKey,
-		"",
-		nonce,
-		client.NetworkID(),
-		"",
-		nil,
-		300_000,
-		gasPrice*1.1,
-		gasPrice*1.1,
-		0,
-	)
-	if err != nil {
-		return errors.Wrap(err, "NewSignedTX")
-	}
-
-	err = client.SendTransaction(ctx, tx)
-	if err != nil {
-		return errors.Wrap(err, "SendTransaction")
-	}
-
-	return nil
-}
-
 type EnvCmd struct {
 	ReEncrypt EnvReEncryptCmd `cmd:"" help:"Change the env file password"`
 	Encrypt   EnvEncryptCmd   `cmd:"" help:"Encrypts all objects with the given tags"`
 	Export    EnvExportCmd    `cmd:"" help:"Export the env filtered by given tags"`
 }
 
-type EnvExportCmd struct{}
+type EnvExportCmd struct {
+	Decrypt bool `optional:"" help:"decrypt the exported env, prompted for unless set or running with --yes"`
+}
 
 func (self *EnvExportCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
-	_, filePath, err := prompt.ReadFile()
-	if err != nil {
-		return errors.Wrap(err, "prompt.ReadFile")
-	}
-
-	_tags, err := prompt.PromptInput("enter tags for objects to be exported separated by a comma: ")
-	if err != nil {
-		return errors.Wrap(err, "prompt tags")
-	}
-	tags := strings.Split(_tags, ",")
-
-	e, err := env.LoadFromFile(filePath, tags...)
+	e, err := cli.Load("enter tags for objects to be exported separated by a comma: ")
 	if err != nil {
-		return errors.Wrap(err, "loading env from file")
+		return err
 	}
 
-	decrypt, err := prompt.PromptConfirm("Decrypt env?")
-	if err != nil {
-		return errors.Wrap(err, "prompt decrypt")
+	decrypt := self.Decrypt
+	if !decrypt && !cli.Yes {
+		decrypt, err = prompt.PromptConfirm("Decrypt env?")
+		if err != nil {
+			return errors.Wrap(err, "prompt decrypt")
+		}
 	}
 
 	if decrypt {
@@ -186,21 +68,15 @@ func (self *EnvExportCmd) Run(cli *CLI, ctx context.Context, logger log.Logger)
 		}
 	}
 
-	content, err := json.MarshalIndent(e, "", "    ")
-	if err != nil {
-		return errors.Wrap(err, "marshal env")
-	}
-	fmt.Println(string(content))
-
-	return nil
+	return cli.Print(EnvResult{Env: e})
 }
 
 type EnvEncryptCmd struct{}
 
 func (self *EnvEncryptCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
-	_, filePath, err := prompt.ReadFile()
+	filePath, err := cli.FilePath()
 	if err != nil {
-		return errors.Wrap(err, "prompt.ReadFile")
+		return err
 	}
 
 	e, err := env.LoadFromFile(filePath)
@@ -208,11 +84,10 @@ func (self *EnvEncryptCmd) Run(cli *CLI, ctx context.Context, logger log.Logger)
 		return errors.Wrap(err, "loading env from file")
 	}
 
-	_tags, err := prompt.PromptInput("enter tags for objects to be encrypted separated by a comma:")
+	tags, err := cli.SelectTags("enter tags for objects to be encrypted separated by a comma:")
 	if err != nil {
-		return errors.Wrap(err, "prompt tags")
+		return err
 	}
-	tags := strings.Split(_tags, ",")
 
 	e, pass, err := env.ReEncryptEnvWithPasswordLoop(e)
 	if err != nil {
@@ -251,24 +126,19 @@ func (self *EnvEncryptCmd) Run(cli *CLI, ctx context.Context, logger log.Logger)
 		return errors.Wrap(err, "decryption verification")
 	}
 
-	content, err := json.MarshalIndent(e, "", "    ")
+	err = saveEnv(filePath, e, nil)
 	if err != nil {
-		return errors.Wrap(err, "marshal env")
-	}
-
-	err = os.WriteFile(filePath, content, os.ModePerm)
-	if err != nil {
-		return errors.Wrap(err, "write env to file")
+		return err
 	}
 
-	level.Info(logger).Log("msg", "env file are encrypted", "tags", _tags)
+	level.Info(logger).Log("msg", "env file are encrypted", "tags", strings.Join(tags, ","))
 	return nil
 }
 
 type EnvReEncryptCmd struct{}
 
 func (self *EnvReEncryptCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
-	_, filePath, err := prompt.ReadFile()
+	filePath, err := cli.FilePath()
 	if err != nil {
 		return err
 	}
@@ -288,14 +158,9 @@ func (self *EnvReEncryptCmd) Run(cli *CLI, ctx context.Context, logger log.Logge
 		return errors.Wrap(err, "decryption verification")
 	}
 
-	content, err := json.MarshalIndent(e, "", "    ")
-	if err != nil {
-		return errors.Wrap(err, "marshal env")
-	}
-
-	err = os.WriteFile(filePath, content, os.ModePerm)
+	err = saveEnv(filePath, e, nil)
 	if err != nil {
-		return errors.Wrap(err, "write env to file")
+		return err
 	}
 
 	level.Info(logger).Log("msg", "env file re-encrypted")
@@ -322,8 +187,7 @@ func (self *EncryptCmd) Run(cli *CLI) error {
 		return errors.Errorf("decryption verification mismatch exp:%v, got:%v", input, decrypted)
 
 	}
-	fmt.Println(encrypted)
-	return nil
+	return cli.Print(ValueResult{Value: encrypted})
 }
 
 type DecryptCmd struct{}
@@ -337,29 +201,16 @@ func (self *DecryptCmd) Run(cli *CLI) error {
 	if err != nil {
 		return err
 	}
-	fmt.Println(decrypted)
-	return nil
-}
-
-type MnemonicCmd struct{}
-
-func (self *MnemonicCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
-	entropy := make([]byte, 32)
-	rand.Seed(time.Now().UnixNano())
-	rand.Read(entropy)
-	// the entropy can be any byte slice, generated how pleased,
-	// as long its bit size is a multiple of 32 and is within
-	// the inclusive range of {128,256}
-	mnemomic, err := bip39.NewMnemonic(entropy)
-	if err != nil {
-		return err
-	}
-	fmt.Println(mnemomic)
-	return nil
+	return cli.Print(ValueResult{Value: decrypted})
 }
 
 type AccountCmd struct {
-	Import   AccountImportCmd  `cmd:"" help:"import an acount by a private key"`
-	New      AccountNewCmd     `cmd:"" help:"generate new pub/priv key accounts"`
-	Balances AccountBalanceCmd `cmd:"" help:"show all eth or erc20 balances"`
+	Import         AccountImportCmd         `cmd:"" help:"import an acount by a private key"`
+	ImportKeystore AccountImportKeystoreCmd `cmd:"" help:"import accounts from keystore V3 files"`
+	ExportKeystore AccountExportKeystoreCmd `cmd:"" help:"export accounts to keystore V3 files"`
+	New            AccountNewCmd            `cmd:"" help:"generate new pub/priv key accounts"`
+	Balances       AccountBalanceCmd        `cmd:"" help:"show all eth or erc20 balances"`
+	Discover       AccountDiscoverCmd       `cmd:"" help:"find the used accounts of a mnemonic"`
+	Sweep          AccountSweepCmd          `cmd:"" help:"move the full ETH and token balances of many accounts to one destination"`
+	Topup          AccountTopupCmd          `cmd:"" help:"send ETH to the accounts with a balance below a minimum"`
 }
@@ -0,0 +1,266 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	client_p "github.com/cryptoriums/packages/client"
+	"github.com/cryptoriums/packages/env"
+	"github.com/cryptoriums/packages/prompt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+type ContractCmd struct {
+	Call ContractCallCmd `cmd:"" help:"call a read only contract method"`
+	Send ContractSendCmd `cmd:"" help:"send a TX to a contract method"`
+	Abi  ContractAbiCmd  `cmd:"" help:"manage the ABIs of the env contracts"`
+}
+
+// ContractFlags select the contract method and its arguments.
+type ContractFlags struct {
+	Contract string   `optional:"" help:"contract address, prompted for when not set"`
+	ABI      string   `optional:"" name:"abi" help:"JSON ABI file or a function signature like 'balanceOf(address) returns (uint256)', defaults to the ABI of the contract added with contract abi add"`
+	Method   string   `arg:"" help:"method name or signature"`
+	Args     []string `arg:"" optional:"" help:"method arguments, arrays are given as JSON arrays"`
+}
+
+// Pack returns the selected contract, the ABI and the method with its encoded call data.
+// Without the ABI flag the ABI of the contract in the env is used.
+func (self *ContractFlags) Pack(e env.Env, registry map[common.Address]abi.ABI) (common.Address, abi.ABI, abi.Method, []byte, error) {
+	contract, err := selectContract(e.Contracts, self.Contract)
+	if err != nil {
+		return common.Address{}, abi.ABI{}, abi.Method{}, nil, errors.Wrap(err, "selectContract")
+	}
+
+	var contractABI abi.ABI
+	var method abi.Method
+	switch {
+	case self.ABI != "":
+		contractABI, err = loadABI(self.ABI)
+		if err != nil {
+			return common.Address{}, abi.ABI{}, abi.Method{}, nil, err
+		}
+		method, err = findMethod(contractABI, self.Method)
+		if err != nil {
+			return common.Address{}, abi.ABI{}, abi.Method{}, nil, err
+		}
+	default:
+		var ok bool
+		contractABI, ok = registry[*contract]
+		if ok {
+			method, err = findMethod(contractABI, self.Method)
+		}
+		if !ok || err != nil {
+			if !strings.Contains(self.Method, "(") {
+				if ok {
+					return common.Address{}, abi.ABI{}, abi.Method{}, nil, err
+				}
+				return common.Address{}, abi.ABI{}, abi.Method{}, nil, errors.New("the method should be a signature when the contract has no ABI")
+			}
+			// A signature ABI has only the method.
+			contractABI, err = parseSignatureABI(self.Method)
+			if err != nil {
+				return common.Address{}, abi.ABI{}, abi.Method{}, nil, err
+			}
+			for _, m := range contractABI.Methods {
+				method = m
+			}
+		}
+	}
+
+	args, err := parseArgs(method.Inputs, self.Args)
+	if err != nil {
+		return common.Address{}, abi.ABI{}, abi.Method{}, nil, errors.Wrapf(err, "method:%v", method.Sig)
+	}
+	input, err := method.Inputs.Pack(args...)
+	if err != nil {
+		return common.Address{}, abi.ABI{}, abi.Method{}, nil, errors.Wrapf(err, "pack method:%v", method.Sig)
+	}
+	return *contract, contractABI, method, append(method.ID, input...), nil
+}
+
+type ContractCallCmd struct {
+	From string `optional:"" help:"address used as the call sender"`
+	ContractFlags
+}
+
+func (self *ContractCallCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	e, err := cli.Load("enter tags separated by a comma: ")
+	if err != nil {
+		return err
+	}
+
+	client, err := client_p.NewClientCachedNetID(ctx, logger, e.Nodes[0].URL)
+	if err != nil {
+		return errors.Wrap(err, "NewClientCachedNetID")
+	}
+
+	registry, err := cli.ABIs()
+	if err != nil {
+		return err
+	}
+	contract, contractABI, method, data, err := self.Pack(e, registry)
+	if err != nil {
+		return err
+	}
+
+	msg := ethereum.CallMsg{To: &contract, Data: data}
+	if self.From != "" {
+		msg.From, err = parseAddress(self.From)
+		if err != nil {
+			return errors.Wrap(err, "from")
+		}
+	}
+	output, err := client.CallContract(ctx, msg, nil)
+	if err != nil {
+		known, err := cli.KnownABIs(contract)
+		if err != nil {
+			return err
+		}
+		reason, reverted, simErr := simulateTx(ctx, client, msg, append([]abi.ABI{contractABI}, known...)...)
+		if simErr == nil && reverted {
+			return errors.Errorf("call reverted:%v", reason)
+		}
+		return errors.Wrap(err, "CallContract")
+	}
+
+	values, err := method.Outputs.Unpack(output)
+	if err != nil {
+		return errors.Wrapf(err, "unpack method:%v output", method.Sig)
+	}
+	return cli.Print(ContractCallResult{Method: method.Sig, Values: formatValues(method.Outputs, values)})
+}
+
+type ContractSendCmd struct {
+	From  string  `optional:"" env:"WALLGER_FROM" help:"sender address, prompted for when not set"`
+	Value string  `optional:"" help:"ETH amount to send with the call to a payable method"`
+	Nonce *uint64 `optional:"" help:"TX nonce, prompted for when not set"`
+	ContractFlags
+	Gas
+}
+
+func (self *ContractSendCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	e, err := cli.Load("enter tags separated by a comma: ")
+	if err != nil {
+		return err
+	}
+
+	client, err := client_p.NewClientCachedNetID(ctx, logger, e.Nodes[0].URL)
+	if err != nil {
+		return errors.Wrap(err, "NewClientCachedNetID")
+	}
+
+	registry, err := cli.ABIs()
+	if err != nil {
+		return err
+	}
+	contract, contractABI, method, data, err := self.Pack(e, registry)
+	if err != nil {
+		return err
+	}
+	self.abis = append(self.abis, contractABI)
+
+	var value *big.Int
+	if self.Value != "" {
+		value, err = ParseAmount(self.Value, ethDecimals)
+		if err != nil {
+			return errors.Wrap(err, "value")
+		}
+		if value.Sign() > 0 && !method.IsPayable() {
+			return errors.Errorf("method:%v isn't payable", method.Sig)
+		}
+	}
+
+	sender, _, err := selectAccountAndDecrypt(e.Accounts, self.From, false, "Select sender's pub address:")
+	if err != nil {
+		return errors.Wrap(err, "select sender")
+	}
+
+	var nonce uint64
+	if self.Nonce != nil {
+		nonce = *self.Nonce
+	} else {
+		nonce, err = prompt.Nonce(ctx, client, sender.Pub)
+		if err != nil {
+			return errors.Wrap(err, "selectNonce")
+		}
+	}
+
+	fees, err := self.Fees(ctx, client, cli.Yes)
+	if err != nil {
+		return err
+	}
+
+	unsigned, err := self.buildTx(ctx, cli, client, sender.Pub, nonce, fees, contract, value, data,
+		fmt.Sprintf("call of:%v on:%v from:%v", method.Sig, contract, sender.Pub),
+	)
+	if err != nil {
+		return err
+	}
+
+	// The return values of the simulation show what the TX is expected to do.
+	if len(method.Outputs) > 0 {
+		values, err := previewOutputs(ctx, client, method, txMsg(sender.Pub, &contract, value, data, fees), self.Force)
+		if err != nil {
+			return err
+		}
+		if values == nil {
+			level.Warn(logger).Log("msg", "forced TX reverts in the simulation, no return values to show", "method", method.Sig)
+		} else {
+			err = cli.Print(ContractCallResult{Method: method.Sig, Values: values})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = cli.Confirm("Confirm " + unsigned.String())
+	if err != nil {
+		return err
+	}
+
+	tx, err := signAndSend(ctx, client, sender, unsigned)
+	if err != nil {
+		return err
+	}
+	err = cli.Print(TxResult{Hash: tx.Hash(), Nonce: nonce, From: sender.Pub})
+	if err != nil {
+		return err
+	}
+
+	receipt, err := self.WaitReceipt(ctx, cli, client, tx, sender.Pub)
+	if receipt != nil && len(receipt.Logs) > 0 {
+		if err := cli.Print(ContractEventsResult{Events: decodeLogs(receipt.Logs, registry, contractABI)}); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// previewOutputs returns the return values of the method simulated with eth_call.
+// Forced TXs that revert have no values and return nil.
+func previewOutputs(ctx context.Context, client bind.ContractCaller, method abi.Method, msg ethereum.CallMsg, force bool) ([]ContractValue, error) {
+	output, err := callPending(ctx, client, msg)
+	if err != nil {
+		if force && isRevert(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "simulate call")
+	}
+	values, err := method.Outputs.Unpack(output)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unpack method:%v output", method.Sig)
+	}
+	return formatValues(method.Outputs, values), nil
+}
@@ -0,0 +1,77 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+)
+
+type fakeCaller struct {
+	output []byte
+	err    error
+}
+
+func (self fakeCaller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (self fakeCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return self.output, self.err
+}
+
+func TestPreviewOutputs(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(`[{"type":"function","name":"mint","inputs":[],"outputs":[{"name":"id","type":"uint256"}]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	method := parsed.Methods["mint"]
+	output, err := method.Outputs.Pack(big.NewInt(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		caller fakeCaller
+		force  bool
+		want   string
+		err    bool
+	}{
+		{name: "output", caller: fakeCaller{output: output}, want: "7"},
+		{name: "revert", caller: fakeCaller{err: errors.New("execution reverted")}, err: true},
+		{name: "forced revert", caller: fakeCaller{err: errors.New("execution reverted")}, force: true},
+		{name: "forced RPC failure", caller: fakeCaller{err: errors.New("connection refused")}, force: true, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := previewOutputs(context.Background(), tt.caller, method, ethereum.CallMsg{}, tt.force)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got:%v", values)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if values != nil {
+					t.Fatalf("expected no values, got:%v", values)
+				}
+				return
+			}
+			if len(values) != 1 || values[0].Value != tt.want {
+				t.Fatalf("got:%v, want:%v", values, tt.want)
+			}
+		})
+	}
+}
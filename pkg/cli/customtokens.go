@@ -0,0 +1,337 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	client_p "github.com/cryptoriums/packages/client"
+	"github.com/cryptoriums/packages/prompt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// CustomToken is an ERC20 token stored in the env file.
+// Tokens with the same symbol and decimals on different chains share one entry.
+type CustomToken struct {
+	Symbol   string
+	Name     string `json:",omitempty"`
+	Decimals uint8
+	// Address is the token address per chain ID.
+	Address map[int64]common.Address
+	Tags    []string `json:",omitempty"`
+}
+
+// hasTag returns true when no tags are given or the token has one of them.
+func (self CustomToken) hasTag(tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	for _, tag := range tags {
+		for _, t := range self.Tags {
+			if strings.EqualFold(t, tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CustomTokens returns the env file tokens of the network.
+func (self *EnvFlags) CustomTokens(netID int64) ([]Token, error) {
+	filePath, err := self.FilePath()
+	if err != nil {
+		return nil, err
+	}
+	ext, err := loadEnvExt(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []Token
+	for _, t := range ext.CustomTokens {
+		if addr, ok := t.Address[netID]; ok {
+			tokens = append(tokens, Token{Name: t.Symbol, Address: addr, Decimals: t.Decimals})
+		}
+	}
+	return tokens, nil
+}
+
+// promptCustomToken prompts for one of the custom tokens by its symbol or number.
+// The numbered list is shown on request to keep long lists out of the way.
+// It returns false when there are no custom tokens or none was selected
+// so the caller can fall back to the built-in tokens.
+func promptCustomToken(tokens []Token) (Token, bool, error) {
+	if len(tokens) == 0 {
+		return Token{}, false, nil
+	}
+	for {
+		input, err := prompt.PromptInput(fmt.Sprintf("Select one of the %v custom tokens by symbol or number, ? to list them or leave empty for the built-in tokens: ", len(tokens)))
+		if err != nil {
+			return Token{}, false, errors.Wrap(err, "custom token prompt")
+		}
+		input = strings.TrimSpace(input)
+		switch input {
+		case "":
+			return Token{}, false, nil
+		case "?":
+			for i, t := range tokens {
+				fmt.Fprintf(os.Stderr, "%v. %v %v\n", i+1, t.Name, t.Address.Hex())
+			}
+			continue
+		}
+		for _, t := range tokens {
+			if strings.EqualFold(t.Name, input) {
+				return t, true, nil
+			}
+		}
+		n, err := strconv.Atoi(input)
+		if err != nil || n < 1 || n > len(tokens) {
+			fmt.Fprintln(os.Stderr, "invalid token number or symbol:", input)
+			continue
+		}
+		return tokens[n-1], true, nil
+	}
+}
+
+// addCustomToken adds the token address for the network
+// to the entry with the same symbol and decimals or to a new entry.
+func addCustomToken(tokens []CustomToken, token CustomToken, netID int64, addr common.Address) ([]CustomToken, error) {
+	for _, t := range tokens {
+		existing, ok := t.Address[netID]
+		if !ok {
+			continue
+		}
+		if existing == addr {
+			return nil, errors.Errorf("token:%v already added as:%v", addr, t.Symbol)
+		}
+		if strings.EqualFold(t.Symbol, token.Symbol) {
+			return nil, errors.Errorf("symbol:%v already used on network:%v by token:%v", t.Symbol, netID, existing)
+		}
+	}
+	for i, t := range tokens {
+		if t.Symbol == token.Symbol && t.Decimals == token.Decimals {
+			tokens[i].Address[netID] = addr
+			for _, tag := range token.Tags {
+				if !tokens[i].hasTag([]string{tag}) {
+					tokens[i].Tags = append(tokens[i].Tags, tag)
+				}
+			}
+			return tokens, nil
+		}
+	}
+	token.Address = map[int64]common.Address{netID: addr}
+	return append(tokens, token), nil
+}
+
+type TokenAddCmd struct {
+	Address string `arg:"" help:"token contract address, the name, symbol and decimals are read from the contract"`
+}
+
+func (self *TokenAddCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	addr, err := parseAddress(self.Address)
+	if err != nil {
+		return err
+	}
+	e, err := cli.Load("enter tags separated by a comma: ")
+	if err != nil {
+		return err
+	}
+	client, err := client_p.NewClientCachedNetID(ctx, logger, e.Nodes[0].URL)
+	if err != nil {
+		return errors.Wrap(err, "NewClientCachedNetID")
+	}
+	netID := client.NetworkID()
+
+	code, err := client.CodeAt(ctx, addr, nil)
+	if err != nil {
+		return errors.Wrap(err, "CodeAt")
+	}
+	if len(code) == 0 {
+		return errors.Errorf("no contract code at:%v on network:%v", addr, netID)
+	}
+
+	token := CustomToken{}
+	if err := callTokenMetadata(ctx, client, addr, "symbol", &token.Symbol); err != nil {
+		return errors.Wrap(err, "token symbol")
+	}
+	if token.Symbol == "" {
+		return errors.Errorf("token:%v has an empty symbol", addr)
+	}
+	if err := callTokenMetadata(ctx, client, addr, "name", &token.Name); err != nil {
+		return errors.Wrap(err, "token name")
+	}
+	if err := callTokenMetadata(ctx, client, addr, "decimals", &token.Decimals); err != nil {
+		return errors.Wrap(err, "token decimals")
+	}
+
+	filePath, full, ext, err := loadEnvWithExt(cli)
+	if err != nil {
+		return err
+	}
+	ext.CustomTokens, err = addCustomToken(ext.CustomTokens, token, netID, addr)
+	if err != nil {
+		return err
+	}
+	err = saveEnv(filePath, full, &ext)
+	if err != nil {
+		return err
+	}
+	level.Info(logger).Log("msg", "token added", "symbol", token.Symbol, "name", token.Name, "decimals", token.Decimals, "network", netID, "address", addr)
+	return nil
+}
+
+type TokenListCmd struct {
+	Chain int64    `optional:"" help:"list only the tokens of this chain ID"`
+	Tags  []string `optional:"" name:"tag" help:"list only the tokens with one of the tags"`
+}
+
+func (self *TokenListCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	filePath, err := cli.FilePath()
+	if err != nil {
+		return err
+	}
+	ext, err := loadEnvExt(filePath)
+	if err != nil {
+		return err
+	}
+	var result TokensResult
+	for _, t := range ext.CustomTokens {
+		if !t.hasTag(self.Tags) {
+			continue
+		}
+		for chainID, addr := range t.Address {
+			if self.Chain != 0 && chainID != self.Chain {
+				continue
+			}
+			result.Tokens = append(result.Tokens, TokenInfo{Symbol: t.Symbol, Name: t.Name, Decimals: t.Decimals, ChainID: chainID, Address: addr, Tags: t.Tags})
+		}
+	}
+	sort.Slice(result.Tokens, func(i, j int) bool {
+		a, b := result.Tokens[i], result.Tokens[j]
+		if a.Symbol != b.Symbol {
+			return a.Symbol < b.Symbol
+		}
+		return a.ChainID < b.ChainID
+	})
+	return cli.Print(result)
+}
+
+type TokenRemoveCmd struct {
+	Token string `arg:"" help:"symbol or address of the token"`
+	Chain int64  `optional:"" help:"remove the token only from this chain ID"`
+}
+
+func (self *TokenRemoveCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	filePath, e, ext, err := loadEnvWithExt(cli)
+	if err != nil {
+		return err
+	}
+
+	var removed int
+	tokens := ext.CustomTokens[:0]
+	for _, t := range ext.CustomTokens {
+		for chainID, addr := range t.Address {
+			if self.Chain != 0 && chainID != self.Chain {
+				continue
+			}
+			if strings.EqualFold(t.Symbol, self.Token) || strings.EqualFold(addr.Hex(), self.Token) {
+				delete(t.Address, chainID)
+				removed++
+				level.Info(logger).Log("msg", "token removed", "symbol", t.Symbol, "network", chainID, "address", addr)
+			}
+		}
+		if len(t.Address) > 0 {
+			tokens = append(tokens, t)
+		}
+	}
+	if removed == 0 {
+		return errors.Errorf("no custom token:%v", self.Token)
+	}
+	ext.CustomTokens = tokens
+
+	return saveEnv(filePath, e, &ext)
+}
+
+// tokenList is a token list in the tokenlists.org schema.
+type tokenList struct {
+	Name   string `json:"name"`
+	Tokens []struct {
+		ChainID  int64    `json:"chainId"`
+		Address  string   `json:"address"`
+		Name     string   `json:"name"`
+		Symbol   string   `json:"symbol"`
+		Decimals uint8    `json:"decimals"`
+		Tags     []string `json:"tags"`
+	} `json:"tokens"`
+}
+
+type TokenImportCmd struct {
+	File   string   `arg:"" type:"existingfile" help:"token list JSON file in the tokenlists.org format"`
+	Chains []int64  `optional:"" name:"chain" help:"import only the tokens of these chain IDs"`
+	Tags   []string `optional:"" name:"tag" help:"import only the tokens with one of the tags of the list"`
+}
+
+func (self *TokenImportCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	content, err := os.ReadFile(self.File)
+	if err != nil {
+		return errors.Wrap(err, "read token list file")
+	}
+	var list tokenList
+	if err := json.Unmarshal(content, &list); err != nil {
+		return errors.Wrap(err, "unmarshal token list")
+	}
+
+	filePath, e, ext, err := loadEnvWithExt(cli)
+	if err != nil {
+		return err
+	}
+
+	var imported, skipped int
+	for _, t := range list.Tokens {
+		if len(self.Chains) > 0 && !containsChain(self.Chains, t.ChainID) {
+			continue
+		}
+		token := CustomToken{Symbol: t.Symbol, Name: t.Name, Decimals: t.Decimals, Tags: t.Tags}
+		if !token.hasTag(self.Tags) {
+			continue
+		}
+		if !common.IsHexAddress(t.Address) || t.Symbol == "" {
+			level.Warn(logger).Log("msg", "skipping invalid token", "symbol", t.Symbol, "address", t.Address, "network", t.ChainID)
+			skipped++
+			continue
+		}
+		tokens, err := addCustomToken(ext.CustomTokens, token, t.ChainID, common.HexToAddress(t.Address))
+		if err != nil {
+			level.Debug(logger).Log("msg", "skipping token", "err", err)
+			skipped++
+			continue
+		}
+		ext.CustomTokens = tokens
+		imported++
+	}
+
+	err = saveEnv(filePath, e, &ext)
+	if err != nil {
+		return err
+	}
+	level.Info(logger).Log("msg", "token list imported", "list", list.Name, "imported", imported, "skipped", skipped)
+	return nil
+}
+
+func containsChain(chains []int64, chainID int64) bool {
+	for _, c := range chains {
+		if c == chainID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/cryptoriums/packages/env"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+)
+
+// EnvExt holds the wallger specific objects that are stored
+// in the env file next to the objects of env.Env.
+// The json names of the fields must match the field names.
+type EnvExt struct {
+	// DerivationPaths are the HD paths of accounts derived from a mnemonic.
+	DerivationPaths map[common.Address]string `json:",omitempty"`
+	// ContractABIs are used to decode the calls, events and errors of the contracts.
+	ContractABIs map[common.Address]ContractABI `json:",omitempty"`
+	// CustomTokens are offered in the token selection next to the built-in tokens.
+	CustomTokens []CustomToken `json:",omitempty"`
+}
+
+func loadEnvExt(filePath string) (EnvExt, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return EnvExt{}, errors.Wrap(err, "read env file")
+	}
+	var ext EnvExt
+	if err := json.Unmarshal(content, &ext); err != nil {
+		return EnvExt{}, errors.Wrap(err, "unmarshal env file")
+	}
+	return ext, nil
+}
+
+// saveEnv writes the env to the file and keeps all objects
+// in the existing file that env.Env doesn't know about.
+// When ext is not nil it replaces the wallger specific objects.
+func saveEnv(filePath string, e env.Env, ext *EnvExt) error {
+	content, err := json.MarshalIndent(e, "", "    ")
+	if err != nil {
+		return errors.Wrap(err, "marshal env")
+	}
+
+	var known map[string]json.RawMessage
+	if err := json.Unmarshal(content, &known); err != nil {
+		return errors.Wrap(err, "unmarshal env")
+	}
+
+	extra := make(map[string]json.RawMessage)
+	if existing, err := os.ReadFile(filePath); err == nil {
+		if err := json.Unmarshal(existing, &extra); err != nil {
+			return errors.Wrap(err, "unmarshal existing env file")
+		}
+	}
+	if ext != nil {
+		extContent, err := json.Marshal(ext)
+		if err != nil {
+			return errors.Wrap(err, "marshal env ext")
+		}
+		var extFields map[string]json.RawMessage
+		if err := json.Unmarshal(extContent, &extFields); err != nil {
+			return errors.Wrap(err, "unmarshal env ext")
+		}
+		// Remove the fields that are now empty and omitted.
+		extType := reflect.TypeOf(*ext)
+		for i := 0; i < extType.NumField(); i++ {
+			delete(extra, extType.Field(i).Name)
+		}
+		for name, v := range extFields {
+			extra[name] = v
+		}
+	}
+	for name := range known {
+		delete(extra, name)
+	}
+
+	if len(extra) > 0 {
+		names := make([]string, 0, len(extra))
+		for name := range extra {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		// Append the extra fields to keep the order of the env.Env fields.
+		buf := bytes.NewBuffer(bytes.TrimSuffix(content, []byte("\n}")))
+		for i, name := range names {
+			if i > 0 || len(known) > 0 {
+				buf.WriteString(",")
+			}
+			var v bytes.Buffer
+			if err := json.Indent(&v, extra[name], "    ", "    "); err != nil {
+				return errors.Wrapf(err, "indent env field:%v", name)
+			}
+			buf.WriteString("\n    \"" + name + "\": " + v.String())
+		}
+		buf.WriteString("\n}")
+		content = buf.Bytes()
+	}
+
+	err = os.WriteFile(filePath, content, os.ModePerm)
+	if err != nil {
+		return errors.Wrap(err, "write env to file")
+	}
+	return nil
+}
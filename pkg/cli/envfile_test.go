@@ -0,0 +1,97 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/cryptoriums/packages/env"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSaveEnv(t *testing.T) {
+	addr := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	const existing = `{
+    "ThirdPartyTool": {"keep": true},
+    "ContractABIs": {"0x00000000000000000000000000000000000000aa": {"File": "abis/token.json"}},
+    "DerivationPaths": {"0x00000000000000000000000000000000000000aa": "m/44'/60'/0'/0/0"}
+}`
+	tests := []struct {
+		name string
+		ext  *EnvExt
+		// extra are the top level fields next to the env.Env ones.
+		extra []string
+		want  EnvExt
+	}{
+		{
+			name:  "keeps the existing objects",
+			extra: []string{"ContractABIs", "DerivationPaths", "ThirdPartyTool"},
+			want: EnvExt{
+				ContractABIs:    map[common.Address]ContractABI{addr: {File: "abis/token.json"}},
+				DerivationPaths: map[common.Address]string{addr: "m/44'/60'/0'/0/0"},
+			},
+		},
+		{
+			name:  "replaces the ext objects",
+			ext:   &EnvExt{CustomTokens: []CustomToken{{Symbol: "TKN", Decimals: 6, Address: map[int64]common.Address{1: addr}}}},
+			extra: []string{"CustomTokens", "ThirdPartyTool"},
+			want:  EnvExt{CustomTokens: []CustomToken{{Symbol: "TKN", Decimals: 6, Address: map[int64]common.Address{1: addr}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "env.json")
+			if err := os.WriteFile(file, []byte(existing), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := saveEnv(file, env.Env{}, tt.ext); err != nil {
+				t.Fatal(err)
+			}
+
+			content, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(content, &fields); err != nil {
+				t.Fatalf("invalid env json:%v\n%s", err, content)
+			}
+			envContent, err := json.Marshal(env.Env{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var envFields map[string]json.RawMessage
+			if err := json.Unmarshal(envContent, &envFields); err != nil {
+				t.Fatal(err)
+			}
+			var extra []string
+			for name := range fields {
+				if _, ok := envFields[name]; !ok {
+					extra = append(extra, name)
+				}
+			}
+			sort.Strings(extra)
+			if !reflect.DeepEqual(extra, tt.extra) {
+				t.Fatalf("fields got:%v, want:%v", extra, tt.extra)
+			}
+			var tool map[string]bool
+			if err := json.Unmarshal(fields["ThirdPartyTool"], &tool); err != nil || !tool["keep"] {
+				t.Fatalf("unexpected ThirdPartyTool:%s", fields["ThirdPartyTool"])
+			}
+
+			ext, err := loadEnvExt(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ext, tt.want) {
+				t.Fatalf("ext got:%+v, want:%+v", ext, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/cryptoriums/packages/prompt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/pkg/errors"
+)
+
+const (
+	gweiDecimals = 9
+	// feeHistoryBlocks is the number of recent blocks used for the default priority fee.
+	feeHistoryBlocks = 20
+	// highGasFee in gwei above which the fees require an extra confirmation.
+	highGasFee = 300
+)
+
+// Gas selects the fees and the gas limit of a TX
+// and sends it after a simulation.
+// Fees that are not set default to ones computed from the
+// recent fee history and are prompted for in interactive runs.
+type Gas struct {
+	GasFlags
+	SendFlags
+
+	// unsignedOut is the file for the unsigned TX when it is built for offline signing.
+	unsignedOut string
+	// abis are used to decode the custom errors of the TX.
+	abis []abi.ABI
+}
+
+type feeBackend interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+}
+
+// feeHistoryReader is implemented by the clients that support eth_feeHistory,
+// the others use the tip suggested by the node.
+type feeHistoryReader interface {
+	FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error)
+}
+
+// GasFlags select the fees and the gas limit of a TX.
+type GasFlags struct {
+	MaxFee        string  `optional:"" env:"WALLGER_MAX_FEE" help:"max fee per gas in gwei, defaults to twice the next block base fee plus the priority fee"`
+	PriorityFee   string  `optional:"" env:"WALLGER_PRIORITY_FEE" help:"max priority fee per gas in gwei, defaults to the fee percentile of the recent blocks"`
+	GasPrice      string  `optional:"" env:"WALLGER_GAS_PRICE" help:"gas price in gwei for a legacy type 0 TX"`
+	Legacy        bool    `optional:"" help:"send a legacy type 0 TX, the default for chains without EIP-1559"`
+	FeePercentile float64 `default:"50" help:"percentile of the priority fees in the recent blocks used for the default priority fee"`
+	GasLimit      *uint64 `optional:"" help:"gas limit, estimated with eth_estimateGas when not set"`
+	GasMultiplier float64 `default:"1.2" help:"safety multiplier of the estimated gas limit"`
+}
+
+// SendFlags apply only to TXs that are broadcast and not to the ones built for offline signing.
+type SendFlags struct {
+	Force bool `optional:"" help:"send the TX even when it reverts in the simulation"`
+	WaitFlags
+}
+
+// anotherRun asks whether to repeat the command
+// except when building an unsigned TX which is written to a single file.
+func (self *Gas) anotherRun(cli *CLI) (bool, error) {
+	if self.unsignedOut != "" {
+		return false, nil
+	}
+	return cli.AnotherRun()
+}
+
+// Fees of a TX in wei.
+// Legacy TXs have only the gas price set.
+type Fees struct {
+	MaxFee   *big.Int
+	Tip      *big.Int
+	GasPrice *big.Int
+}
+
+func (self Fees) Legacy() bool {
+	return self.GasPrice != nil
+}
+
+// Cap returns the maximum price per gas that the TX can pay.
+func (self Fees) Cap() *big.Int {
+	if self.Legacy() {
+		return self.GasPrice
+	}
+	return self.MaxFee
+}
+
+func (self Fees) String() string {
+	if self.Legacy() {
+		return "gas price:" + FormatAmount(self.GasPrice, gweiDecimals) + " gwei"
+	}
+	return "max fee:" + FormatAmount(self.MaxFee, gweiDecimals) + " gwei, priority fee:" + FormatAmount(self.Tip, gweiDecimals) + " gwei"
+}
+
+// Fees returns the fees from the flags where the missing ones are
+// prompted for with a default computed from the node fee history.
+// The fee history isn't queried when all fees are set.
+func (self *Gas) Fees(ctx context.Context, client feeBackend, yes bool) (Fees, error) {
+	var suggested Fees
+	if self.GasPrice == "" && (self.Legacy || self.MaxFee == "" || self.PriorityFee == "") {
+		var err error
+		suggested, err = suggestFees(ctx, client, self.FeePercentile)
+		if err != nil {
+			return Fees{}, errors.Wrap(err, "suggestFees")
+		}
+	}
+
+	var fees Fees
+	var err error
+	if self.Legacy || self.GasPrice != "" || suggested.Legacy() {
+		if suggested.MaxFee != nil {
+			// The next block base fee plus the tip.
+			suggested.GasPrice = new(big.Int).Add(suggested.MaxFee, suggested.Tip)
+			suggested.GasPrice.Div(suggested.GasPrice, big.NewInt(2))
+		}
+		fees.GasPrice, err = gweiInput(self.GasPrice, "enter TX gas price", suggested.GasPrice, yes)
+		if err != nil {
+			return Fees{}, err
+		}
+	} else {
+		fees.Tip, err = gweiInput(self.PriorityFee, "enter TX priority fee", suggested.Tip, yes)
+		if err != nil {
+			return Fees{}, err
+		}
+		// Keep the default max fee above the selected tip.
+		var defMaxFee *big.Int
+		if suggested.MaxFee != nil {
+			defMaxFee = new(big.Int).Add(new(big.Int).Sub(suggested.MaxFee, suggested.Tip), fees.Tip)
+		}
+		fees.MaxFee, err = gweiInput(self.MaxFee, "enter TX max fee", defMaxFee, yes)
+		if err != nil {
+			return Fees{}, err
+		}
+		if fees.MaxFee.Cmp(fees.Tip) < 0 {
+			return Fees{}, errors.Errorf("max fee:%v is lower than the priority fee:%v", FormatAmount(fees.MaxFee, gweiDecimals), FormatAmount(fees.Tip, gweiDecimals))
+		}
+	}
+
+	if fees.Cap().Cmp(gwei(highGasFee)) > 0 && !yes {
+		confirmed, err := prompt.PromptConfirm(fmt.Sprintf("confirm high gas fee:%v", fees))
+		if err != nil || !confirmed {
+			return Fees{}, errors.New("canceled")
+		}
+	}
+	return fees, nil
+}
+
+// Limit returns the gas limit from the flags or
+// the node estimate of the TX increased by the safety multiplier.
+func (self *Gas) Limit(ctx context.Context, client ethereum.GasEstimator, msg ethereum.CallMsg) (uint64, error) {
+	if self.GasLimit != nil {
+		return *self.GasLimit, nil
+	}
+	if self.GasMultiplier < 1 {
+		return 0, errors.Errorf("gas multiplier:%v is lower than 1", self.GasMultiplier)
+	}
+	estimate, err := client.EstimateGas(ctx, msg)
+	if err != nil {
+		return 0, errors.Wrap(err, "EstimateGas")
+	}
+	// Plain ETH transfers to EOAs always use the same gas.
+	if estimate == params.TxGas {
+		return estimate, nil
+	}
+	return uint64(math.Ceil(float64(estimate) * self.GasMultiplier)), nil
+}
+
+// gweiInput parses the input in gwei or prompts for it in interactive runs with the given default.
+func gweiInput(input string, msg string, def *big.Int, yes bool) (*big.Int, error) {
+	if input != "" {
+		v, err := ParseAmount(input, gweiDecimals)
+		if err != nil {
+			return nil, errors.Wrap(err, msg)
+		}
+		return v, nil
+	}
+	if yes {
+		return def, nil
+	}
+	for {
+		_input, err := prompt.PromptInput(fmt.Sprintf("%v(gwei) or leave empty for %v: ", msg, FormatAmount(def, gweiDecimals)))
+		if err != nil {
+			return nil, errors.Wrap(err, "gas fee prompt")
+		}
+		if _input == "" {
+			return def, nil
+		}
+		v, err := ParseAmount(_input, gweiDecimals)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "invalid gas fee:", err)
+			continue
+		}
+		return v, nil
+	}
+}
+
+// suggestFees computes the fees from the fee history of the recent blocks.
+// The tip is the median of the given percentile of the priority fees in each block and
+// the max fee allows the base fee to double before the TX becomes unexecutable.
+// For chains without EIP-1559 it returns the legacy gas price suggested by the node.
+func suggestFees(ctx context.Context, client feeBackend, percentile float64) (Fees, error) {
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return Fees{}, errors.Wrap(err, "HeaderByNumber")
+	}
+	if header.BaseFee == nil {
+		gasPrice, err := client.SuggestGasPrice(ctx)
+		if err != nil {
+			return Fees{}, errors.Wrap(err, "SuggestGasPrice")
+		}
+		return Fees{GasPrice: gasPrice}, nil
+	}
+
+	baseFee := header.BaseFee
+	var tips []*big.Int
+	if historyClient, ok := client.(feeHistoryReader); ok {
+		history, err := historyClient.FeeHistory(ctx, feeHistoryBlocks, nil, []float64{percentile})
+		if err != nil {
+			return Fees{}, errors.Wrap(err, "FeeHistory")
+		}
+		// The last base fee is the one of the next block.
+		if len(history.BaseFee) > 0 {
+			baseFee = history.BaseFee[len(history.BaseFee)-1]
+		}
+		tips = historyTips(history)
+	}
+	var tip *big.Int
+	if len(tips) > 0 {
+		sort.Slice(tips, func(i, j int) bool { return tips[i].Cmp(tips[j]) < 0 })
+		tip = tips[len(tips)/2]
+	} else {
+		tip, err = client.SuggestGasTipCap(ctx)
+		if err != nil {
+			return Fees{}, errors.Wrap(err, "SuggestGasTipCap")
+		}
+	}
+	maxFee := new(big.Int).Mul(baseFee, big.NewInt(2))
+	maxFee.Add(maxFee, tip)
+
+	return Fees{MaxFee: maxFee, Tip: tip}, nil
+}
+
+// historyTips returns the non zero priority fees of the fee history blocks.
+func historyTips(history *ethereum.FeeHistory) []*big.Int {
+	var tips []*big.Int
+	for _, reward := range history.Reward {
+		// Empty blocks have zero rewards.
+		if len(reward) > 0 && reward[0].Sign() > 0 {
+			tips = append(tips, reward[0])
+		}
+	}
+	return tips
+}
+
+// gwei converts a gwei value to wei.
+func gwei(v int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(v), big.NewInt(params.GWei))
+}
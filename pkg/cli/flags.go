@@ -0,0 +1,216 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"strings"
+
+	"github.com/cryptoriums/packages/env"
+	"github.com/cryptoriums/packages/prompt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/jinzhu/copier"
+	"github.com/pkg/errors"
+)
+
+// EnvFlags selects the env file and the tags used to filter its objects.
+// Values that are not set are prompted for and cached so that
+// commands running in a loop ask only once.
+type EnvFlags struct {
+	EnvFile string  `optional:"" env:"WALLGER_ENV_FILE" help:"path to the env file, prompted for when not set"`
+	Tags    *string `optional:"" env:"WALLGER_TAGS" help:"tags separated by a comma to filter the env objects, prompted for when not set"`
+
+	abis map[common.Address]abi.ABI
+}
+
+func (self *EnvFlags) FilePath() (string, error) {
+	if self.EnvFile != "" {
+		return self.EnvFile, nil
+	}
+	_, filePath, err := prompt.ReadFile()
+	if err != nil {
+		return "", errors.Wrap(err, "prompt.ReadFile")
+	}
+	self.EnvFile = filePath
+	return filePath, nil
+}
+
+func (self *EnvFlags) SelectTags(msg string) ([]string, error) {
+	if self.Tags == nil {
+		_tags, err := prompt.PromptInput(msg)
+		if err != nil {
+			return nil, errors.Wrap(err, "prompt tags")
+		}
+		self.Tags = &_tags
+	}
+	return strings.Split(*self.Tags, ","), nil
+}
+
+// Load reads the env file filtered by the selected tags.
+func (self *EnvFlags) Load(tagsMsg string) (env.Env, error) {
+	filePath, err := self.FilePath()
+	if err != nil {
+		return env.Env{}, err
+	}
+	tags, err := self.SelectTags(tagsMsg)
+	if err != nil {
+		return env.Env{}, err
+	}
+	e, err := env.LoadFromFile(filePath, tags...)
+	if err != nil {
+		return env.Env{}, errors.Wrap(err, "loading env from file")
+	}
+	return e, nil
+}
+
+// Confirm prompts for a confirmation unless the yes flag is set.
+func (self *CLI) Confirm(msg string) error {
+	if self.Yes {
+		return nil
+	}
+	confirmed, err := prompt.PromptConfirm(msg)
+	if err != nil || !confirmed {
+		return errors.New("canceled")
+	}
+	return nil
+}
+
+// AnotherRun asks whether to repeat a command.
+// Non interactive runs with the yes flag run only once.
+func (self *CLI) AnotherRun() (bool, error) {
+	if self.Yes {
+		return false, nil
+	}
+	anotherRun, err := prompt.PromptConfirm("Another run?")
+	if err != nil {
+		return false, errors.Wrap(err, "prompt for another run")
+	}
+	return anotherRun, nil
+}
+
+// selectAccountAndDecrypt returns the env account with the given address
+// or prompts for one when the address is empty.
+func selectAccountAndDecrypt(accs []env.Account, addr string, firstRun bool, msg string) (env.Account, string, error) {
+	if addr == "" {
+		return env.SelectAccountAndDecrypt(accs, firstRun, msg)
+	}
+	acc, err := findAccount(accs, addr)
+	if err != nil {
+		return env.Account{}, "", err
+	}
+	return decryptAccount(acc)
+}
+
+// selectEnvAccount returns the env account with the given address
+// or prompts for one when the address is empty without decrypting it.
+func selectEnvAccount(accs []env.Account, addr string, msg string) (env.Account, error) {
+	if addr == "" {
+		return env.SelectAccount(accs, false, msg)
+	}
+	return findAccount(accs, addr)
+}
+
+// decryptAccount prompts for the password of an encrypted account key
+// and returns the account with the decrypted key and the password.
+func decryptAccount(acc env.Account) (env.Account, string, error) {
+	if !env.IsEncrypted(acc.Priv) {
+		return acc, "", nil
+	}
+	var pass string
+	var err error
+	acc.Priv, pass, err = env.DecryptWithPasswordLoop(acc.Priv)
+	if err != nil {
+		return env.Account{}, "", errors.Wrap(err, "DecryptWithPasswordLoop")
+	}
+	return acc, pass, nil
+}
+
+// selectAccount returns the account with the given address or prompts for one when the address is empty.
+// The address doesn't need to be in the env.
+func selectAccount(accs []env.Account, addr string, firstRun bool, msg string) (env.Account, error) {
+	if addr == "" {
+		return env.SelectAccount(accs, firstRun, msg)
+	}
+	pub, err := parseAddress(addr)
+	if err != nil {
+		return env.Account{}, err
+	}
+	for _, acc := range accs {
+		if acc.Pub == pub {
+			return acc, nil
+		}
+	}
+	return env.Account{Pub: pub}, nil
+}
+
+// accountsAndContracts returns the env accounts and the contracts as accounts tagged with contract
+// so that both can be selected as a receiver.
+func accountsAndContracts(e env.Env) []env.Account {
+	// Deep copy to not modify the original slice.
+	var accs []env.Account
+	copier.CopyWithOption(&accs, e.Accounts, copier.Option{DeepCopy: true})
+	for _, contract := range e.Contracts {
+		accs = append(accs, env.Account{
+			Pub:  contract.Address,
+			Tags: append(contract.Tags, "contract"),
+		})
+	}
+	return accs
+}
+
+func findAccount(accs []env.Account, addr string) (env.Account, error) {
+	pub, err := parseAddress(addr)
+	if err != nil {
+		return env.Account{}, err
+	}
+	for _, acc := range accs {
+		if acc.Pub == pub {
+			return acc, nil
+		}
+	}
+	return env.Account{}, errors.Errorf("account not in the env:%v", addr)
+}
+
+func parseAddress(addr string) (common.Address, error) {
+	if !common.IsHexAddress(addr) {
+		return common.Address{}, errors.Errorf("not a hex address:%v", addr)
+	}
+	return common.HexToAddress(addr), nil
+}
+
+// selectProxy returns the proxy contract with the given address or prompts for an optional one.
+// Non interactive runs don't use a proxy unless it is set.
+func selectProxy(contracts []env.Contract, addr string, yes bool) (*common.Address, error) {
+	if addr != "" {
+		proxy, err := parseAddress(addr)
+		if err != nil {
+			return nil, err
+		}
+		return &proxy, nil
+	}
+	if yes {
+		return nil, nil
+	}
+	proxy, _, err := prompt.Contract(contracts, false, true)
+	if err != nil {
+		return nil, err
+	}
+	return proxy, nil
+}
+
+// selectContract returns the contract with the given address or prompts for one when the address is empty.
+func selectContract(contracts []env.Contract, addr string) (*common.Address, error) {
+	if addr != "" {
+		contract, err := parseAddress(addr)
+		if err != nil {
+			return nil, err
+		}
+		return &contract, nil
+	}
+	contract, _, err := prompt.Contract(contracts, false, false)
+	if err != nil {
+		return nil, err
+	}
+	return contract, nil
+}
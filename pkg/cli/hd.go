@@ -0,0 +1,92 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"crypto/ecdsa"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/pkg/errors"
+	"github.com/tyler-smith/go-bip39"
+)
+
+const (
+	SchemeBIP44      = "bip44"
+	SchemeLedgerLive = "ledger-live"
+	SchemeMEWLegacy  = "mew-legacy"
+	SchemeCustom     = "custom"
+)
+
+// derivationSchemes are the path templates of the common wallets where {i} is the account index.
+var derivationSchemes = map[string]string{
+	// Used by MetaMask, Trezor and most other wallets.
+	SchemeBIP44: "m/44'/60'/0'/0/{i}",
+	// Also used by the previous wallger versions.
+	SchemeLedgerLive: "m/44'/60'/{i}'/0/0",
+	SchemeMEWLegacy:  "m/44'/60'/0'/{i}",
+}
+
+// HDFlags selects how accounts are derived from a mnemonic.
+type HDFlags struct {
+	Scheme     string `default:"bip44" enum:"bip44,ledger-live,mew-legacy,custom" help:"derivation scheme: bip44 m/44'/60'/0'/0/i, ledger-live m/44'/60'/i'/0/0 (used by older wallger versions), mew-legacy m/44'/60'/0'/i or custom"`
+	Path       string `optional:"" help:"derivation path template for the custom scheme where {i} is the account index, e.g. m/44'/60'/1'/0/{i}"`
+	Start      uint32 `default:"0" help:"index of the first derived account"`
+	Passphrase bool   `optional:"" help:"prompt for a BIP39 passphrase"`
+}
+
+// DerivationPath returns the path of the account with the given index.
+func (self *HDFlags) DerivationPath(i uint32) (string, error) {
+	template := derivationSchemes[self.Scheme]
+	if self.Scheme == SchemeCustom {
+		if !strings.Contains(self.Path, "{i}") {
+			return "", errors.Errorf("custom derivation path:%v doesn't include the {i} index placeholder", self.Path)
+		}
+		template = self.Path
+	}
+	if template == "" {
+		return "", errors.Errorf("unknown derivation scheme:%v", self.Scheme)
+	}
+	return strings.ReplaceAll(template, "{i}", strconv.FormatUint(uint64(i), 10)), nil
+}
+
+// MasterKey prompts for the passphrase when enabled and returns the master key of the mnemonic.
+func (self *HDFlags) MasterKey(mnemonic string) (*hdkeychain.ExtendedKey, error) {
+	var passphrase string
+	if self.Passphrase {
+		var err error
+		passphrase, err = promptPassphrase()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return newMasterKey(mnemonic, passphrase)
+}
+
+func newMasterKey(mnemonic, passphrase string) (*hdkeychain.ExtendedKey, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(strings.TrimSpace(mnemonic), passphrase)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid mnemonic")
+	}
+	master, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		return nil, errors.Wrap(err, "hdkeychain.NewMaster")
+	}
+	return master, nil
+}
+
+func deriveKey(master *hdkeychain.ExtendedKey, path string) (*ecdsa.PrivateKey, error) {
+	derivationPath, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "accounts.ParseDerivationPath")
+	}
+	key, err := derivePrivateKey(master, derivationPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "derivePrivateKey")
+	}
+	return key, nil
+}
@@ -0,0 +1,81 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestDerivationPath(t *testing.T) {
+	tests := []struct {
+		scheme string
+		path   string
+		index  uint32
+		want   string
+		err    bool
+	}{
+		{scheme: SchemeBIP44, index: 0, want: "m/44'/60'/0'/0/0"},
+		{scheme: SchemeBIP44, index: 12, want: "m/44'/60'/0'/0/12"},
+		{scheme: SchemeLedgerLive, index: 3, want: "m/44'/60'/3'/0/0"},
+		{scheme: SchemeMEWLegacy, index: 3, want: "m/44'/60'/0'/3"},
+		{scheme: SchemeCustom, path: "m/44'/60'/1'/0/{i}", index: 5, want: "m/44'/60'/1'/0/5"},
+		{scheme: SchemeCustom, path: "m/44'/60'/0'/0/0", err: true},
+		{scheme: "trezor", err: true},
+	}
+	for _, tt := range tests {
+		flags := HDFlags{Scheme: tt.scheme, Path: tt.path}
+		got, err := flags.DerivationPath(tt.index)
+		if tt.err {
+			if err == nil {
+				t.Errorf("scheme:%v path:%v expected an error, got:%v", tt.scheme, tt.path, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("scheme:%v path:%v error:%v", tt.scheme, tt.path, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("scheme:%v path:%v got:%v, want:%v", tt.scheme, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	const mnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	tests := []struct {
+		path string
+		want string
+		err  bool
+	}{
+		{path: "m/44'/60'/0'/0/0", want: "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"},
+		{path: "m/44'/60'/0'/0/x", err: true},
+	}
+	master, err := newMasterKey(mnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		key, err := deriveKey(master, tt.path)
+		if tt.err {
+			if err == nil {
+				t.Errorf("path:%v expected an error", tt.path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("path:%v error:%v", tt.path, err)
+			continue
+		}
+		if got := crypto.PubkeyToAddress(key.PublicKey).Hex(); got != tt.want {
+			t.Errorf("path:%v got:%v, want:%v", tt.path, got, tt.want)
+		}
+	}
+
+	if _, err := newMasterKey("abandon abandon", ""); err == nil {
+		t.Error("expected an invalid mnemonic error")
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cryptoriums/packages/env"
+	tx_p "github.com/cryptoriums/packages/tx"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gprompt "github.com/ethereum/go-ethereum/console/prompt"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+type AccountImportKeystoreCmd struct {
+	Path string `arg:"" type:"path" help:"keystore file or a directory with keystore files"`
+}
+
+func (self *AccountImportKeystoreCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	files, err := keystoreFiles(logger, self.Path)
+	if err != nil {
+		return err
+	}
+
+	var passwords []string
+	var newAccs []env.Account
+	var result KeystoreResult
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return errors.Wrapf(err, "read keystore file:%v", file)
+		}
+
+		// Try the passwords of the previous files before asking for a new one.
+		var key *keystore.Key
+		for _, pass := range passwords {
+			if key, err = keystore.DecryptKey(content, pass); err == nil {
+				break
+			}
+		}
+		if key == nil {
+			pass, err := gprompt.Stdin.PromptPassword("Password for " + filepath.Base(file) + ": ")
+			if err != nil {
+				return errors.Wrap(err, "keystore password prompt")
+			}
+			key, err = keystore.DecryptKey(content, pass)
+			if err != nil {
+				return errors.Wrapf(err, "decrypt keystore file:%v", file)
+			}
+			passwords = append(passwords, pass)
+		}
+
+		newAccs = append(newAccs, env.Account{
+			Pub:  key.Address,
+			Priv: hexutil.Encode(crypto.FromECDSA(key.PrivateKey)),
+		})
+		result.Accounts = append(result.Accounts, KeystoreAccount{Address: key.Address, File: file})
+	}
+
+	err = cli.Print(result)
+	if err != nil {
+		return err
+	}
+
+	// Keys from keystore files are never stored in plaintext.
+	return addAccounts(cli, logger, newAccs, nil, true)
+}
+
+// keystoreFiles returns the keystore file or the keystore files in the directory.
+// Files in the directory that aren't keystore files are skipped.
+func keystoreFiles(logger log.Logger, path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "keystore path")
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read keystore dir")
+	}
+	var files []string
+	for _, entry := range entries {
+		// Skip the editor backups and hidden files the same way geth does.
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || strings.HasSuffix(entry.Name(), "~") {
+			continue
+		}
+		file := filepath.Join(path, entry.Name())
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read keystore file:%v", file)
+		}
+		if !isKeystore(content) {
+			level.Warn(logger).Log("msg", "skipping a file that isn't a keystore", "file", file)
+			continue
+		}
+		files = append(files, file)
+	}
+	if len(files) == 0 {
+		return nil, errors.Errorf("no keystore files in:%v", path)
+	}
+	return files, nil
+}
+
+// isKeystore returns true for files with the address and crypto fields of the keystore format.
+func isKeystore(content []byte) bool {
+	var key struct {
+		Address string          `json:"address"`
+		Crypto  json.RawMessage `json:"crypto"`
+	}
+	if err := json.Unmarshal(content, &key); err != nil {
+		return false
+	}
+	return key.Address != "" && len(key.Crypto) > 0
+}
+
+type AccountExportKeystoreCmd struct {
+	Out       string   `arg:"" type:"path" help:"directory to write the keystore files to or a file path when exporting a single account"`
+	Addresses []string `optional:"" name:"address" help:"addresses of the accounts to export, all accounts matching the tags when not set"`
+	Light     bool     `optional:"" help:"use the light scrypt parameters which are faster, but less secure"`
+}
+
+func (self *AccountExportKeystoreCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	e, err := cli.Load("enter tags for accounts to be exported separated by a comma: ")
+	if err != nil {
+		return err
+	}
+
+	accs := e.Accounts
+	if len(self.Addresses) > 0 {
+		accs = nil
+		for _, addr := range self.Addresses {
+			acc, err := findAccount(e.Accounts, addr)
+			if err != nil {
+				return err
+			}
+			accs = append(accs, acc)
+		}
+	}
+	if len(accs) == 0 {
+		return errors.New("no accounts to export")
+	}
+
+	if env.IsEncryptedEnv(env.Env{Accounts: accs}) {
+		decrypted, _, err := env.DecryptEnvWithPasswordLoop(env.Env{Accounts: accs})
+		if err != nil {
+			return errors.Wrap(err, "DecryptEnvWithPasswordLoop")
+		}
+		accs = decrypted.Accounts
+	}
+
+	pass, err := gprompt.Stdin.PromptPassword("Keystore password: ")
+	if err != nil {
+		return errors.Wrap(err, "keystore password prompt")
+	}
+	confirm, err := gprompt.Stdin.PromptPassword("Repeat the keystore password: ")
+	if err != nil {
+		return errors.Wrap(err, "keystore password prompt")
+	}
+	if pass != confirm {
+		return errors.New("passwords don't match")
+	}
+
+	scryptN, scryptP := keystore.StandardScryptN, keystore.StandardScryptP
+	if self.Light {
+		scryptN, scryptP = keystore.LightScryptN, keystore.LightScryptP
+	}
+
+	info, err := os.Stat(self.Out)
+	toDir := err == nil && info.IsDir()
+	if !toDir && len(accs) > 1 {
+		return errors.Errorf("exporting multiple accounts requires an existing directory:%v", self.Out)
+	}
+
+	var result KeystoreResult
+	for _, acc := range accs {
+		ethAcc, err := tx_p.AccountFromPrvKey(acc.Priv)
+		if err != nil {
+			return errors.Wrap(err, "AccountFromPrvKey")
+		}
+		content, err := keystore.EncryptKey(&keystore.Key{
+			Id:         uuid.New(),
+			Address:    ethAcc.PublicKey,
+			PrivateKey: ethAcc.PrivateKey,
+		}, pass, scryptN, scryptP)
+		if err != nil {
+			return errors.Wrap(err, "keystore.EncryptKey")
+		}
+
+		// Verify decryption.
+		key, err := keystore.DecryptKey(content, pass)
+		if err != nil {
+			return errors.Wrap(err, "decryption verification")
+		}
+		if key.Address != acc.Pub {
+			return errors.Errorf("decryption verification mismatch exp:%v, got:%v", acc.Pub, key.Address)
+		}
+
+		file := self.Out
+		if toDir {
+			file = filepath.Join(self.Out, keystoreFileName(acc.Pub))
+		}
+		// Same permissions as geth.
+		err = os.WriteFile(file, content, 0600)
+		if err != nil {
+			return errors.Wrap(err, "write keystore file")
+		}
+		result.Accounts = append(result.Accounts, KeystoreAccount{Address: acc.Pub, File: file})
+	}
+
+	level.Info(logger).Log("msg", "accounts exported to keystore files", "count", len(result.Accounts))
+	return cli.Print(result)
+}
+
+// keystoreFileName returns the geth file name for the account like UTC--<created_at UTC ISO8601>--<address hex>.
+func keystoreFileName(addr common.Address) string {
+	return "UTC--" + time.Now().UTC().Format("2006-01-02T15-04-05.000000000Z") + "--" + hex.EncodeToString(addr[:])
+}
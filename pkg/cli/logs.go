@@ -0,0 +1,71 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+)
+
+// LogsFlags select the blocks scanned for event logs.
+type LogsFlags struct {
+	FromBlock  *uint64 `optional:"" help:"first block to scan for events, defaults to the last --blocks blocks"`
+	Blocks     uint64  `default:"100000" help:"number of recent blocks to scan when --from-block isn't set"`
+	BlockRange uint64  `default:"10000" help:"max number of blocks in one logs query"`
+}
+
+// FilterLogs returns the logs of the query from the first block to the head.
+// Without a first block only the recent blocks are scanned.
+// The logs are queried in block ranges since nodes limit the size of the results.
+func (self *LogsFlags) FilterLogs(ctx context.Context, client bind.ContractBackend, query ethereum.FilterQuery) ([]types.Log, error) {
+	if self.BlockRange == 0 {
+		return nil, errors.New("block range should be at least 1")
+	}
+	if self.FromBlock == nil && self.Blocks == 0 {
+		return nil, errors.New("blocks should be at least 1")
+	}
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "HeaderByNumber")
+	}
+	last := head.Number.Uint64()
+
+	var first uint64
+	switch {
+	case self.FromBlock != nil:
+		first = *self.FromBlock
+	case last >= self.Blocks:
+		first = last - self.Blocks + 1
+	}
+
+	var logs []types.Log
+	for from := first; from <= last; from += self.BlockRange {
+		to := from + self.BlockRange - 1
+		if to > last {
+			to = last
+		}
+		query.FromBlock, query.ToBlock = new(big.Int).SetUint64(from), new(big.Int).SetUint64(to)
+		result, err := client.FilterLogs(ctx, query)
+		if err != nil {
+			return nil, errors.Wrapf(err, "FilterLogs blocks:%v-%v", from, to)
+		}
+		logs = append(logs, result...)
+	}
+	return logs, nil
+}
+
+// addressTopics converts the addresses to indexed event topics.
+func addressTopics(addrs []common.Address) []common.Hash {
+	topics := make([]common.Hash, len(addrs))
+	for i, addr := range addrs {
+		topics[i] = common.BytesToHash(addr.Bytes())
+	}
+	return topics
+}
@@ -0,0 +1,121 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	gprompt "github.com/ethereum/go-ethereum/console/prompt"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/tyler-smith/go-bip39"
+)
+
+type MnemonicCmd struct {
+	Words      int    `default:"24" enum:"12,15,18,21,24" help:"number of mnemonic words: 12, 15, 18, 21 or 24"`
+	Dice       string `optional:"" help:"dice rolls (1-6) mixed into the generated entropy"`
+	Coins      string `optional:"" help:"coin flips (0/1 or h/t) mixed into the generated entropy"`
+	Passphrase bool   `optional:"" help:"prompt for a BIP39 passphrase used to derive the verification address"`
+}
+
+func (self *MnemonicCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	userEntropy, err := self.userEntropy()
+	if err != nil {
+		return err
+	}
+
+	// Each word encodes 11 bits where 1 bit in every 33 is the checksum.
+	entropy := make([]byte, self.Words*4/3)
+	if _, err := rand.Read(entropy); err != nil {
+		return errors.Wrap(err, "reading random entropy")
+	}
+	if len(userEntropy) > 0 {
+		mixed := sha256.Sum256(append(entropy, userEntropy...))
+		entropy = mixed[:len(entropy)]
+	}
+
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return errors.Wrap(err, "bip39.NewMnemonic")
+	}
+
+	// Verify that the mnemonic decodes back to the same entropy.
+	decoded, err := bip39.EntropyFromMnemonic(mnemonic)
+	if err != nil {
+		return errors.Wrap(err, "mnemonic verification")
+	}
+	if !bytes.Equal(decoded, entropy) {
+		return errors.New("mnemonic verification mismatch")
+	}
+
+	var passphrase string
+	if self.Passphrase {
+		passphrase, err = promptPassphrase()
+		if err != nil {
+			return err
+		}
+	}
+
+	master, err := newMasterKey(mnemonic, passphrase)
+	if err != nil {
+		return err
+	}
+	path := strings.ReplaceAll(derivationSchemes[SchemeBIP44], "{i}", "0")
+	key, err := deriveKey(master, path)
+	if err != nil {
+		return err
+	}
+
+	return cli.Print(MnemonicResult{
+		Mnemonic: mnemonic,
+		Checksum: mnemonicChecksum(entropy),
+		Path:     path,
+		Address:  crypto.PubkeyToAddress(key.PublicKey).Hex(),
+	})
+}
+
+func (self *MnemonicCmd) userEntropy() ([]byte, error) {
+	for _, r := range self.Dice {
+		if r < '1' || r > '6' {
+			return nil, errors.Errorf("invalid dice roll:%q", r)
+		}
+	}
+	for _, r := range strings.ToLower(self.Coins) {
+		if !strings.ContainsRune("01ht", r) {
+			return nil, errors.Errorf("invalid coin flip:%q", r)
+		}
+	}
+	if self.Dice == "" && self.Coins == "" {
+		return nil, nil
+	}
+	return []byte("dice:" + self.Dice + "coins:" + strings.ToLower(self.Coins)), nil
+}
+
+// mnemonicChecksum returns the checksum bits that the last mnemonic word includes.
+func mnemonicChecksum(entropy []byte) string {
+	bits := len(entropy) * 8 / 32
+	hash := sha256.Sum256(entropy)
+	return fmt.Sprintf("%0*b", bits, hash[0]>>(8-bits))
+}
+
+func promptPassphrase() (string, error) {
+	passphrase, err := gprompt.Stdin.PromptPassword("BIP39 passphrase: ")
+	if err != nil {
+		return "", errors.Wrap(err, "passphrase prompt")
+	}
+	confirm, err := gprompt.Stdin.PromptPassword("Repeat the BIP39 passphrase: ")
+	if err != nil {
+		return "", errors.Wrap(err, "passphrase prompt")
+	}
+	if passphrase != confirm {
+		return "", errors.New("passphrases don't match")
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,84 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+func TestMnemonicChecksum(t *testing.T) {
+	tests := []struct {
+		name    string
+		entropy []byte
+		want    string
+	}{
+		// The BIP39 vector "abandon ... about".
+		{name: "12 words zero", entropy: make([]byte, 16), want: "0011"},
+		{name: "12 words", entropy: bytes.Repeat([]byte{0x7f}, 16)},
+		{name: "18 words", entropy: bytes.Repeat([]byte{0x80}, 24)},
+		{name: "24 words", entropy: bytes.Repeat([]byte{0xff}, 32)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mnemonic, err := bip39.NewMnemonic(tt.entropy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The checksum is in the low bits of the last word.
+			words := strings.Fields(mnemonic)
+			index, ok := bip39.GetWordIndex(words[len(words)-1])
+			if !ok {
+				t.Fatalf("unknown word:%v", words[len(words)-1])
+			}
+			bits := len(tt.entropy) * 8 / 32
+			want := fmt.Sprintf("%0*b", bits, index&(1<<bits-1))
+			if tt.want != "" && tt.want != want {
+				t.Fatalf("vector checksum got:%v, want:%v", want, tt.want)
+			}
+
+			if got := mnemonicChecksum(tt.entropy); got != want {
+				t.Fatalf("got:%v, want:%v", got, want)
+			}
+		})
+	}
+}
+
+func TestUserEntropy(t *testing.T) {
+	tests := []struct {
+		dice  string
+		coins string
+		want  string
+		err   bool
+	}{
+		{want: ""},
+		{dice: "123456", want: "dice:123456coins:"},
+		{coins: "HT01", want: "dice:coins:ht01"},
+		{dice: "16", coins: "h", want: "dice:16coins:h"},
+		{dice: "7", err: true},
+		{dice: "0", err: true},
+		{coins: "x", err: true},
+	}
+	for _, tt := range tests {
+		cmd := MnemonicCmd{Dice: tt.dice, Coins: tt.coins}
+		got, err := cmd.userEntropy()
+		if tt.err {
+			if err == nil {
+				t.Errorf("dice:%v coins:%v expected an error", tt.dice, tt.coins)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("dice:%v coins:%v error:%v", tt.dice, tt.coins, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("dice:%v coins:%v got:%q, want:%q", tt.dice, tt.coins, got, tt.want)
+		}
+	}
+}
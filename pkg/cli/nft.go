@@ -0,0 +1,439 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	client_p "github.com/cryptoriums/packages/client"
+	"github.com/cryptoriums/packages/env"
+	"github.com/cryptoriums/packages/prompt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	ERC721  = "ERC721"
+	ERC1155 = "ERC1155"
+)
+
+// ERC165 interface IDs of the NFT standards.
+var (
+	erc721InterfaceID  = [4]byte{0x80, 0xac, 0x58, 0xcd}
+	erc1155InterfaceID = [4]byte{0xd9, 0xb6, 0x7a, 0x26}
+)
+
+var (
+	transferTopic       = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+	transferSingleTopic = crypto.Keccak256Hash([]byte("TransferSingle(address,address,address,uint256,uint256)"))
+	transferBatchTopic  = crypto.Keccak256Hash([]byte("TransferBatch(address,address,address,uint256[],uint256[])"))
+)
+
+type NftCmd struct {
+	Transfer          NftTransferCmd          `cmd:"" help:"transfer ERC721 or ERC1155 tokens with safeTransferFrom"`
+	Approve           NftApproveCmd           `cmd:"" help:"approve a spender of an ERC721 token"`
+	SetApprovalForAll NftSetApprovalForAllCmd `cmd:"" help:"approve or revoke an operator of all tokens of an ERC721 or ERC1155 contract"`
+	Owned             NftOwnedCmd             `cmd:"" help:"list the NFTs of the env accounts"`
+}
+
+// NftFlags select the NFT contract and the sender.
+type NftFlags struct {
+	Contract string  `optional:"" help:"NFT contract address, prompted for when not set"`
+	From     string  `optional:"" env:"WALLGER_FROM" help:"sender address, prompted for when not set"`
+	Nonce    *uint64 `optional:"" help:"TX nonce, prompted for when not set"`
+}
+
+type NftTransferCmd struct {
+	To      string   `optional:"" env:"WALLGER_TO" help:"receiver address, prompted for when not set"`
+	IDs     []string `required:"" name:"id" help:"token IDs, repeated for multiple tokens"`
+	Amounts []string `optional:"" name:"amount" help:"ERC1155 amounts of the token IDs in the same order, 1 for each ID when not set"`
+	Data    string   `optional:"" help:"hex data passed to the receiver contract"`
+	NftFlags
+	Gas
+}
+
+func (self *NftTransferCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	nft, err := self.NftFlags.load(ctx, cli, logger, &self.Gas)
+	if err != nil {
+		return err
+	}
+
+	receiverAcc, err := selectAccount(accountsAndContracts(nft.env), self.To, false, "Select receiver's pub address:")
+	if err != nil {
+		return errors.Wrap(err, "select receiver")
+	}
+	receiver := receiverAcc.Pub
+
+	ids, err := parseUint256s(self.IDs)
+	if err != nil {
+		return errors.Wrap(err, "token ID")
+	}
+	var data []byte
+	if self.Data != "" {
+		data, err = hexutil.Decode(self.Data)
+		if err != nil {
+			return errors.Wrap(err, "data")
+		}
+	}
+
+	fees, err := self.Fees(ctx, nft.client, cli.Yes)
+	if err != nil {
+		return err
+	}
+
+	if nft.standard == ERC721 {
+		if len(self.Amounts) > 0 {
+			return errors.New("ERC721 transfers have no amounts")
+		}
+		// ERC721 has no batch transfer so each token is a TX.
+		for i, id := range ids {
+			input, err := packMethod("safeTransferFrom(address,address,uint256,bytes)", nft.sender.Pub, receiver, id, data)
+			if err != nil {
+				return err
+			}
+			err = self.sendTx(ctx, cli, nft.client, nft.sender, nft.nonce+uint64(i), fees, nft.contract, nil, input,
+				fmt.Sprintf("transfer of %v:%v id:%v from:%v, to:%v", ERC721, nft.contract, id, nft.sender.Pub, receiver),
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	amounts := make([]*big.Int, len(ids))
+	for i := range amounts {
+		amounts[i] = big.NewInt(1)
+	}
+	if len(self.Amounts) > 0 {
+		if len(self.Amounts) != len(ids) {
+			return errors.Errorf("expected %v amounts, got:%v", len(ids), len(self.Amounts))
+		}
+		amounts, err = parseUint256s(self.Amounts)
+		if err != nil {
+			return errors.Wrap(err, "amount")
+		}
+	}
+
+	var input []byte
+	if len(ids) == 1 {
+		input, err = packMethod("safeTransferFrom(address,address,uint256,uint256,bytes)", nft.sender.Pub, receiver, ids[0], amounts[0], data)
+	} else {
+		input, err = packMethod("safeBatchTransferFrom(address,address,uint256[],uint256[],bytes)", nft.sender.Pub, receiver, ids, amounts, data)
+	}
+	if err != nil {
+		return err
+	}
+	return self.sendTx(ctx, cli, nft.client, nft.sender, nft.nonce, fees, nft.contract, nil, input,
+		fmt.Sprintf("transfer of %v:%v ids:%v amounts:%v from:%v, to:%v", ERC1155, nft.contract, ids, amounts, nft.sender.Pub, receiver),
+	)
+}
+
+type NftApproveCmd struct {
+	To string `optional:"" env:"WALLGER_TO" help:"spender address, prompted for when not set"`
+	ID string `required:"" name:"id" help:"token ID"`
+	NftFlags
+	Gas
+}
+
+func (self *NftApproveCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	nft, err := self.NftFlags.load(ctx, cli, logger, &self.Gas)
+	if err != nil {
+		return err
+	}
+	if nft.standard != ERC721 {
+		return errors.New("only ERC721 tokens are approved by ID, use set-approval-for-all for ERC1155")
+	}
+
+	spender, err := promptAddress(self.To, "Select spender address: ")
+	if err != nil {
+		return errors.Wrap(err, "spender")
+	}
+	ids, err := parseUint256s([]string{self.ID})
+	if err != nil {
+		return errors.Wrap(err, "token ID")
+	}
+
+	fees, err := self.Fees(ctx, nft.client, cli.Yes)
+	if err != nil {
+		return err
+	}
+	input, err := packMethod("approve(address,uint256)", spender, ids[0])
+	if err != nil {
+		return err
+	}
+	return self.sendTx(ctx, cli, nft.client, nft.sender, nft.nonce, fees, nft.contract, nil, input,
+		fmt.Sprintf("approve of %v:%v id:%v from:%v, to:%v", ERC721, nft.contract, ids[0], nft.sender.Pub, spender),
+	)
+}
+
+type NftSetApprovalForAllCmd struct {
+	Operator            string `optional:"" help:"operator address, prompted for when not set"`
+	Revoke              bool   `optional:"" help:"revoke the operator approval"`
+	AllowUnknownSpender bool   `optional:"" help:"allow approving operators that aren't env contracts in non interactive runs"`
+	NftFlags
+	Gas
+}
+
+func (self *NftSetApprovalForAllCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	nft, err := self.NftFlags.load(ctx, cli, logger, &self.Gas)
+	if err != nil {
+		return err
+	}
+
+	operator, err := promptAddress(self.Operator, "Select operator address: ")
+	if err != nil {
+		return errors.Wrap(err, "operator")
+	}
+	// An operator can transfer all tokens of the contract like an unlimited approval.
+	if !self.Revoke {
+		err = confirmUnlimited(cli, nft.env.Contracts, operator, self.AllowUnknownSpender)
+		if err != nil {
+			return err
+		}
+	}
+
+	fees, err := self.Fees(ctx, nft.client, cli.Yes)
+	if err != nil {
+		return err
+	}
+	input, err := packMethod("setApprovalForAll(address,bool)", operator, !self.Revoke)
+	if err != nil {
+		return err
+	}
+	action := "approval for all"
+	if self.Revoke {
+		action = "revoke of approval for all"
+	}
+	return self.sendTx(ctx, cli, nft.client, nft.sender, nft.nonce, fees, nft.contract, nil, input,
+		fmt.Sprintf("%v of %v:%v from:%v, operator:%v", action, nft.standard, nft.contract, nft.sender.Pub, operator),
+	)
+}
+
+// nftTx is the selected NFT contract and the sender of a TX to it.
+type nftTx struct {
+	env      env.Env
+	client   client_p.EthClient
+	contract common.Address
+	standard string
+	sender   env.Account
+	nonce    uint64
+}
+
+// load selects the NFT contract, detects its standard and selects the sender and the TX nonce.
+func (self *NftFlags) load(ctx context.Context, cli *CLI, logger log.Logger, gas *Gas) (nftTx, error) {
+	e, err := cli.Load("enter tags separated by a comma: ")
+	if err != nil {
+		return nftTx{}, err
+	}
+
+	client, err := client_p.NewClientCachedNetID(ctx, logger, e.Nodes[0].URL)
+	if err != nil {
+		return nftTx{}, errors.Wrap(err, "NewClientCachedNetID")
+	}
+
+	contract, err := selectContract(e.Contracts, self.Contract)
+	if err != nil {
+		return nftTx{}, errors.Wrap(err, "selectContract")
+	}
+	standard, err := nftStandard(ctx, client, *contract)
+	if err != nil {
+		return nftTx{}, err
+	}
+
+	sender, _, err := gas.selectSender(e.Accounts, self.From, false, "Select sender's pub address:")
+	if err != nil {
+		return nftTx{}, errors.Wrap(err, "select sender")
+	}
+
+	var nonce uint64
+	if self.Nonce != nil {
+		nonce = *self.Nonce
+	} else {
+		nonce, err = prompt.Nonce(ctx, client, sender.Pub)
+		if err != nil {
+			return nftTx{}, errors.Wrap(err, "selectNonce")
+		}
+	}
+	return nftTx{env: e, client: client, contract: *contract, standard: standard, sender: sender, nonce: nonce}, nil
+}
+
+type NftOwnedCmd struct {
+	Contracts []string `optional:"" name:"contract" help:"NFT contract addresses to check, all contracts with transfers to the accounts when not set"`
+	LogsFlags
+}
+
+func (self *NftOwnedCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	e, err := cli.Load("enter tags separated by a comma: ")
+	if err != nil {
+		return err
+	}
+	if len(e.Accounts) == 0 {
+		return errors.New("no env accounts with the selected tags")
+	}
+
+	client, err := client_p.NewClientCachedNetID(ctx, logger, e.Nodes[0].URL)
+	if err != nil {
+		return errors.Wrap(err, "NewClientCachedNetID")
+	}
+
+	var contracts []common.Address
+	for _, input := range self.Contracts {
+		addr, err := parseAddress(input)
+		if err != nil {
+			return errors.Wrap(err, "contract")
+		}
+		contracts = append(contracts, addr)
+	}
+	var owners []common.Address
+	for _, acc := range e.Accounts {
+		owners = append(owners, acc.Pub)
+	}
+
+	// The incoming transfers give the candidates and the
+	// current owners and balances are checked on the contracts.
+	erc721Logs, err := self.FilterLogs(ctx, client, ethereum.FilterQuery{
+		Addresses: contracts,
+		Topics:    [][]common.Hash{{transferTopic}, nil, addressTopics(owners)},
+	})
+	if err != nil {
+		return err
+	}
+	erc1155Logs, err := self.FilterLogs(ctx, client, ethereum.FilterQuery{
+		Addresses: contracts,
+		Topics:    [][]common.Hash{{transferSingleTopic, transferBatchTopic}, nil, nil, addressTopics(owners)},
+	})
+	if err != nil {
+		return err
+	}
+
+	type holding struct {
+		owner    common.Address
+		contract common.Address
+		standard string
+		id       string
+	}
+	var candidates []holding
+	found := make(map[holding]bool)
+	add := func(h holding) {
+		if !found[h] {
+			found[h] = true
+			candidates = append(candidates, h)
+		}
+	}
+	for _, log := range erc721Logs {
+		// ERC20 transfers have the same event ID without an indexed token ID.
+		if len(log.Topics) != 4 {
+			continue
+		}
+		add(holding{
+			owner:    common.BytesToAddress(log.Topics[2].Bytes()),
+			contract: log.Address,
+			standard: ERC721,
+			id:       log.Topics[3].Big().String(),
+		})
+	}
+	uint256Ty, _ := abi.NewType("uint256", "", nil)
+	uint256ArrTy, _ := abi.NewType("uint256[]", "", nil)
+	for _, log := range erc1155Logs {
+		if len(log.Topics) != 4 {
+			continue
+		}
+		owner := common.BytesToAddress(log.Topics[3].Bytes())
+		var ids []*big.Int
+		if log.Topics[0] == transferSingleTopic {
+			values, err := abi.Arguments{{Type: uint256Ty}, {Type: uint256Ty}}.Unpack(log.Data)
+			if err != nil {
+				return errors.Wrapf(err, "unpack TransferSingle of:%v", log.Address)
+			}
+			ids = append(ids, values[0].(*big.Int))
+		} else {
+			values, err := abi.Arguments{{Type: uint256ArrTy}, {Type: uint256ArrTy}}.Unpack(log.Data)
+			if err != nil {
+				return errors.Wrapf(err, "unpack TransferBatch of:%v", log.Address)
+			}
+			ids = append(ids, values[0].([]*big.Int)...)
+		}
+		for _, id := range ids {
+			add(holding{owner: owner, contract: log.Address, standard: ERC1155, id: id.String()})
+		}
+	}
+
+	var result NftHoldingsResult
+	for _, h := range candidates {
+		id, _ := new(big.Int).SetString(h.id, 10)
+		if h.standard == ERC721 {
+			values, err := callMethod(ctx, client, h.contract, "ownerOf(uint256) returns (address)", id)
+			if err != nil {
+				// Burned tokens revert.
+				if isRevert(err) {
+					continue
+				}
+				return errors.Wrapf(err, "owner of:%v id:%v", h.contract, h.id)
+			}
+			if values[0].(common.Address) != h.owner {
+				continue
+			}
+			result.Holdings = append(result.Holdings, NftHolding{Owner: h.owner, Contract: h.contract, Standard: h.standard, ID: h.id, Amount: "1"})
+			continue
+		}
+		values, err := callMethod(ctx, client, h.contract, "balanceOf(address,uint256) returns (uint256)", h.owner, id)
+		if err != nil {
+			return errors.Wrapf(err, "balance of:%v id:%v", h.contract, h.id)
+		}
+		if balance := values[0].(*big.Int); balance.Sign() > 0 {
+			result.Holdings = append(result.Holdings, NftHolding{Owner: h.owner, Contract: h.contract, Standard: h.standard, ID: h.id, Amount: balance.String()})
+		}
+	}
+	return cli.Print(result)
+}
+
+// nftStandard detects whether the contract is an ERC721 or an ERC1155 with ERC165.
+func nftStandard(ctx context.Context, client client_p.EthClient, contract common.Address) (string, error) {
+	for _, standard := range []struct {
+		name string
+		id   [4]byte
+	}{{ERC721, erc721InterfaceID}, {ERC1155, erc1155InterfaceID}} {
+		values, err := callMethod(ctx, client, contract, "supportsInterface(bytes4) returns (bool)", standard.id)
+		if err != nil {
+			return "", errors.Wrapf(err, "contract:%v doesn't support ERC165", contract)
+		}
+		if values[0].(bool) {
+			return standard.name, nil
+		}
+	}
+	return "", errors.Errorf("contract:%v isn't an ERC721 or ERC1155", contract)
+}
+
+func parseUint256s(inputs []string) ([]*big.Int, error) {
+	values := make([]*big.Int, len(inputs))
+	for i, input := range inputs {
+		v, ok := new(big.Int).SetString(strings.TrimSpace(input), 0)
+		if !ok || v.Sign() < 0 || v.BitLen() > 256 {
+			return nil, errors.Errorf("invalid uint256:%v", input)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// promptAddress parses the address or prompts for it when the input is empty.
+func promptAddress(input string, msg string) (common.Address, error) {
+	if input == "" {
+		var err error
+		input, err = prompt.PromptInput(msg)
+		if err != nil {
+			return common.Address{}, errors.Wrap(err, "address prompt")
+		}
+	}
+	return parseAddress(strings.TrimSpace(input))
+}
@@ -0,0 +1,262 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	client_p "github.com/cryptoriums/packages/client"
+	tx_p "github.com/cryptoriums/packages/tx"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+)
+
+// UnsignedTx is a TX built on an online machine
+// with all the values needed to sign it on an offline machine.
+type UnsignedTx struct {
+	ChainID  int64          `json:"chainId"`
+	From     common.Address `json:"from"`
+	To       common.Address `json:"to"`
+	Nonce    uint64         `json:"nonce"`
+	Value    *big.Int       `json:"value"`
+	Data     hexutil.Bytes  `json:"data"`
+	Gas      uint64         `json:"gas"`
+	MaxFee   *big.Int       `json:"maxFee,omitempty"`
+	Tip      *big.Int       `json:"tip,omitempty"`
+	GasPrice *big.Int       `json:"gasPrice,omitempty"`
+	// Description is what the command that built the TX does.
+	// It isn't signed so the other fields are what matters.
+	Description string `json:"description"`
+}
+
+func (self UnsignedTx) Fees() Fees {
+	return Fees{MaxFee: self.MaxFee, Tip: self.Tip, GasPrice: self.GasPrice}
+}
+
+func (self UnsignedTx) String() string {
+	return fmt.Sprintf("%v, %v, gas limit:%v, max TX fee:%v ETH", self.Description, self.Fees(), self.Gas, FormatAmount(maxTxFee(self), ethDecimals))
+}
+
+func (self UnsignedTx) Sign(prv *ecdsa.PrivateKey) (*types.Transaction, error) {
+	to := self.To
+	tx, err := newSignedTx(prv, self.ChainID, self.Nonce, &to, self.Value, self.Data, self.Gas, self.Fees())
+	if err != nil {
+		return nil, errors.Wrap(err, "newSignedTx")
+	}
+	return tx, nil
+}
+
+func writeUnsignedTx(cli *CLI, file string, unsigned UnsignedTx) error {
+	content, err := json.MarshalIndent(unsigned, "", "    ")
+	if err != nil {
+		return errors.Wrap(err, "marshal unsigned TX")
+	}
+	// Don't overwrite a TX that might not be signed yet.
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return errors.Wrap(err, "create unsigned TX file")
+	}
+	defer f.Close()
+	if _, err := f.Write(content); err != nil {
+		return errors.Wrap(err, "write unsigned TX file")
+	}
+	return cli.Print(UnsignedTxResult{File: file, From: unsigned.From, Nonce: unsigned.Nonce, Description: unsigned.Description})
+}
+
+type TxBuildCmd struct {
+	Transfer TxBuildTransferCmd `cmd:"" help:"build an unsigned ETH or token transfer TX"`
+	Approve  TxBuildApproveCmd  `cmd:"" help:"build an unsigned token approve TX"`
+	SetOwner TxBuildSetOwnerCmd `cmd:"" help:"build an unsigned set owner TX"`
+}
+
+type TxBuildTransferCmd struct {
+	Out string `arg:"" type:"path" help:"file to write the unsigned TX to"`
+	TokenTransferFlags
+	GasFlags
+}
+
+func (self *TxBuildTransferCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	cmd := TokenTransferCmd{TokenTransferFlags: self.TokenTransferFlags, Gas: Gas{GasFlags: self.GasFlags, unsignedOut: self.Out}}
+	return cmd.Run(cli, ctx, logger)
+}
+
+type TxBuildApproveCmd struct {
+	Out string `arg:"" type:"path" help:"file to write the unsigned TX to"`
+	TokenApproveFlags
+	GasFlags
+}
+
+func (self *TxBuildApproveCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	cmd := TokenApproveCmd{TokenApproveFlags: self.TokenApproveFlags, Gas: Gas{GasFlags: self.GasFlags, unsignedOut: self.Out}}
+	return cmd.Run(cli, ctx, logger)
+}
+
+type TxBuildSetOwnerCmd struct {
+	Out string `arg:"" type:"path" help:"file to write the unsigned TX to"`
+	SetOwnerFlags
+	GasFlags
+}
+
+func (self *TxBuildSetOwnerCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	cmd := SetOwnerCmd{SetOwnerFlags: self.SetOwnerFlags, Gas: Gas{GasFlags: self.GasFlags, unsignedOut: self.Out}}
+	return cmd.Run(cli, ctx, logger)
+}
+
+type TxSignCmd struct {
+	In  string `arg:"" type:"existingfile" help:"unsigned TX file created by tx build"`
+	Out string `optional:"" type:"path" help:"file to write the signed raw TX to, only printed when not set"`
+}
+
+// Run signs the TX with the env keys and doesn't connect to a node
+// so that it can run on an offline machine.
+func (self *TxSignCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	content, err := os.ReadFile(self.In)
+	if err != nil {
+		return errors.Wrap(err, "read unsigned TX file")
+	}
+	var unsigned UnsignedTx
+	if err := json.Unmarshal(content, &unsigned); err != nil {
+		return errors.Wrap(err, "unmarshal unsigned TX")
+	}
+	if unsigned.Value == nil {
+		unsigned.Value = big.NewInt(0)
+	}
+
+	e, err := cli.Load("enter tags separated by a comma: ")
+	if err != nil {
+		return err
+	}
+	acc, _, err := selectAccountAndDecrypt(e.Accounts, unsigned.From.Hex(), false, "")
+	if err != nil {
+		return errors.Wrap(err, "TX sender")
+	}
+
+	// The description isn't signed so show the call that the data encodes.
+	known, err := cli.KnownABIs(unsigned.To)
+	if err != nil {
+		return err
+	}
+	data := unsigned.Data.String()
+	if call := decodeCall(unsigned.Data, known...); call != "" {
+		data = call
+	}
+
+	err = cli.Confirm(fmt.Sprintf("Confirm signing of %v\nchain:%v, nonce:%v, from:%v, to:%v, value:%v ETH, data:%v",
+		unsigned, unsigned.ChainID, unsigned.Nonce, unsigned.From, unsigned.To, FormatAmount(unsigned.Value, ethDecimals), data,
+	))
+	if err != nil {
+		return err
+	}
+
+	ethAcc, err := tx_p.AccountFromPrvKey(acc.Priv)
+	if err != nil {
+		return errors.Wrap(err, "AccountFromPrvKey")
+	}
+	tx, err := unsigned.Sign(ethAcc.PrivateKey)
+	if err != nil {
+		return err
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return errors.Wrap(err, "marshal signed TX")
+	}
+
+	if self.Out != "" {
+		err = os.WriteFile(self.Out, []byte(hexutil.Encode(raw)), 0644)
+		if err != nil {
+			return errors.Wrap(err, "write signed TX file")
+		}
+	}
+	return cli.Print(SignedTxResult{Hash: tx.Hash(), From: unsigned.From, Nonce: tx.Nonce(), Raw: raw})
+}
+
+type TxBroadcastCmd struct {
+	Tx    string `arg:"" help:"signed raw TX in hex or a file with it created by tx sign"`
+	Force bool   `optional:"" help:"send the TX even when it reverts in the simulation"`
+	WaitFlags
+}
+
+func (self *TxBroadcastCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	input := self.Tx
+	if !strings.HasPrefix(input, "0x") {
+		content, err := os.ReadFile(input)
+		if err != nil {
+			return errors.Wrap(err, "read signed TX file")
+		}
+		input = string(content)
+	}
+	raw, err := hexutil.Decode(strings.TrimSpace(input))
+	if err != nil {
+		return errors.Wrap(err, "decode signed TX")
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return errors.Wrap(err, "unmarshal signed TX")
+	}
+
+	e, err := cli.Load("enter tags separated by a comma: ")
+	if err != nil {
+		return err
+	}
+	client, err := client_p.NewClientCachedNetID(ctx, logger, e.Nodes[0].URL)
+	if err != nil {
+		return errors.Wrap(err, "NewClientCachedNetID")
+	}
+
+	if tx.ChainId().Cmp(big.NewInt(client.NetworkID())) != 0 {
+		return errors.Errorf("TX chain:%v doesn't match the node chain:%v", tx.ChainId(), client.NetworkID())
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return errors.Wrap(err, "TX sender")
+	}
+
+	// The state might have changed since the TX was built.
+	msg := ethereum.CallMsg{From: from, To: tx.To(), Value: tx.Value(), Data: tx.Data(), Gas: tx.Gas()}
+	if tx.Type() == types.DynamicFeeTxType {
+		msg.GasFeeCap, msg.GasTipCap = tx.GasFeeCap(), tx.GasTipCap()
+	} else {
+		msg.GasPrice = tx.GasPrice()
+	}
+	var to common.Address
+	if tx.To() != nil {
+		to = *tx.To()
+	}
+	known, err := cli.KnownABIs(to)
+	if err != nil {
+		return err
+	}
+	reason, reverted, err := simulateTx(ctx, client, msg, known...)
+	if err != nil {
+		return errors.Wrap(err, "simulateTx")
+	}
+	if reverted {
+		if !self.Force {
+			return errors.Errorf("TX reverts in the simulation:%v, use --force to send it anyway", reason)
+		}
+		fmt.Fprintln(os.Stderr, "TX reverts in the simulation:", reason)
+	}
+
+	err = client.SendTransaction(ctx, tx)
+	if err != nil {
+		return errors.Wrap(err, "SendTransaction")
+	}
+
+	err = cli.Print(TxResult{Hash: tx.Hash(), Nonce: tx.Nonce(), From: from})
+	if err != nil {
+		return err
+	}
+	_, err = self.WaitReceipt(ctx, cli, client, tx, from)
+	return err
+}
@@ -0,0 +1,745 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cryptoriums/packages/env"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	OutputText  = "text"
+	OutputTable = "table"
+	OutputJSON  = "json"
+)
+
+// Result is the output of a command.
+// The json schema of every result is stable so
+// that scripts can parse it.
+type Result interface {
+	Text() string
+	// Table returns the table rows where the first row is the header.
+	Table() [][]string
+}
+
+// Print writes the result to stdout in the selected output format.
+// JSON results are written one per line.
+func (self *CLI) Print(r Result) error {
+	switch self.Output {
+	case OutputJSON:
+		content, err := json.Marshal(r)
+		if err != nil {
+			return errors.Wrap(err, "marshal result")
+		}
+		fmt.Println(string(content))
+	case OutputTable:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, row := range r.Table() {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return w.Flush()
+	default:
+		fmt.Println(r.Text())
+	}
+	return nil
+}
+
+type ErrorResult struct {
+	Error string `json:"error"`
+}
+
+func (self ErrorResult) Text() string {
+	return "error: " + self.Error
+}
+
+func (self ErrorResult) Table() [][]string {
+	return [][]string{{"ERROR"}, {self.Error}}
+}
+
+type TxResult struct {
+	Hash  common.Hash    `json:"hash"`
+	Nonce uint64         `json:"nonce"`
+	From  common.Address `json:"from"`
+	// Replaces is the hash of the pending TX replaced by this one.
+	Replaces common.Hash `json:"replaces,omitempty"`
+}
+
+func (self TxResult) Text() string {
+	if self.Replaces != (common.Hash{}) {
+		return fmt.Sprintf("Tx Created nonce %v hash %v replaces %v", self.Nonce, self.Hash, self.Replaces)
+	}
+	return fmt.Sprintf("Tx Created nonce %v hash %v", self.Nonce, self.Hash)
+}
+
+func (self TxResult) Table() [][]string {
+	return [][]string{
+		{"HASH", "NONCE", "FROM", "REPLACES"},
+		{self.Hash.Hex(), strconv.FormatUint(self.Nonce, 10), self.From.Hex(), self.Replaces.Hex()},
+	}
+}
+
+type UnsignedTxResult struct {
+	File        string         `json:"file"`
+	From        common.Address `json:"from"`
+	Nonce       uint64         `json:"nonce"`
+	Description string         `json:"description"`
+}
+
+func (self UnsignedTxResult) Text() string {
+	return fmt.Sprintf("Unsigned Tx nonce %v from %v written to %v", self.Nonce, self.From, self.File)
+}
+
+func (self UnsignedTxResult) Table() [][]string {
+	return [][]string{
+		{"FILE", "FROM", "NONCE", "DESCRIPTION"},
+		{self.File, self.From.Hex(), strconv.FormatUint(self.Nonce, 10), self.Description},
+	}
+}
+
+type SignedTxResult struct {
+	Hash  common.Hash    `json:"hash"`
+	From  common.Address `json:"from"`
+	Nonce uint64         `json:"nonce"`
+	Raw   hexutil.Bytes  `json:"raw"`
+}
+
+func (self SignedTxResult) Text() string {
+	return fmt.Sprintf("Signed Tx nonce %v hash %v\n%v", self.Nonce, self.Hash, self.Raw)
+}
+
+func (self SignedTxResult) Table() [][]string {
+	return [][]string{
+		{"HASH", "FROM", "NONCE", "RAW"},
+		{self.Hash.Hex(), self.From.Hex(), strconv.FormatUint(self.Nonce, 10), self.Raw.String()},
+	}
+}
+
+type BatchTotal struct {
+	From  common.Address `json:"from"`
+	Token string         `json:"token"`
+	Count int            `json:"count"`
+	// Amount includes the max TX fees for ETH.
+	Amount       string `json:"amount"`
+	Balance      string `json:"balance"`
+	Insufficient bool   `json:"insufficient"`
+}
+
+type BatchSummaryResult struct {
+	Totals []BatchTotal `json:"totals"`
+}
+
+func (self BatchSummaryResult) Text() string {
+	var lines []string
+	for _, total := range self.Totals {
+		line := fmt.Sprintf("%v %v transfers:%v total:%v balance:%v", total.From, total.Token, total.Count, total.Amount, total.Balance)
+		if total.Insufficient {
+			line += " INSUFFICIENT"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (self BatchSummaryResult) Table() [][]string {
+	rows := [][]string{{"FROM", "TOKEN", "TRANSFERS", "TOTAL", "BALANCE", "INSUFFICIENT"}}
+	for _, total := range self.Totals {
+		rows = append(rows, []string{
+			total.From.Hex(),
+			total.Token,
+			strconv.Itoa(total.Count),
+			total.Amount,
+			total.Balance,
+			strconv.FormatBool(total.Insufficient),
+		})
+	}
+	return rows
+}
+
+type Sweep struct {
+	From   common.Address `json:"from"`
+	Token  string         `json:"token"`
+	Amount string         `json:"amount"`
+}
+
+// SkippedSweep is an account that isn't swept because one of its TXs can't be built.
+type SkippedSweep struct {
+	From   common.Address `json:"from"`
+	Token  string         `json:"token"`
+	Reason string         `json:"reason"`
+}
+
+type SweepResult struct {
+	Sweeps  []Sweep        `json:"sweeps"`
+	Skipped []SkippedSweep `json:"skipped,omitempty"`
+}
+
+func (self SweepResult) Text() string {
+	var lines []string
+	for _, sweep := range self.Sweeps {
+		lines = append(lines, fmt.Sprintf("%v %v %v", sweep.From, sweep.Token, sweep.Amount))
+	}
+	for _, skipped := range self.Skipped {
+		lines = append(lines, fmt.Sprintf("%v skipped at %v: %v", skipped.From, skipped.Token, skipped.Reason))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (self SweepResult) Table() [][]string {
+	rows := [][]string{{"FROM", "TOKEN", "AMOUNT", "SKIPPED"}}
+	for _, sweep := range self.Sweeps {
+		rows = append(rows, []string{sweep.From.Hex(), sweep.Token, sweep.Amount, ""})
+	}
+	for _, skipped := range self.Skipped {
+		rows = append(rows, []string{skipped.From.Hex(), skipped.Token, "", skipped.Reason})
+	}
+	return rows
+}
+
+type Topup struct {
+	Address common.Address `json:"address"`
+	Tags    []string       `json:"tags"`
+	Balance string         `json:"balance"`
+	Amount  string         `json:"amount"`
+}
+
+type TopupResult struct {
+	From   common.Address `json:"from"`
+	Topups []Topup        `json:"topups"`
+	Total  string         `json:"total"`
+	// AboveMaxTotal is the max total when the total is above it.
+	AboveMaxTotal string `json:"aboveMaxTotal,omitempty"`
+}
+
+func (self TopupResult) Text() string {
+	lines := []string{fmt.Sprintf("Top ups from %v total %v ETH", self.From, self.Total)}
+	for _, topup := range self.Topups {
+		lines = append(lines, fmt.Sprintf("%v %v balance:%v top up:%v", topup.Address, strings.Join(topup.Tags, ","), topup.Balance, topup.Amount))
+	}
+	if self.AboveMaxTotal != "" {
+		lines = append(lines, fmt.Sprintf("Total is above the max total %v ETH", self.AboveMaxTotal))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (self TopupResult) Table() [][]string {
+	rows := [][]string{{"ADDRESS", "TAGS", "BALANCE", "TOP UP"}}
+	for _, topup := range self.Topups {
+		rows = append(rows, []string{topup.Address.Hex(), strings.Join(topup.Tags, ","), topup.Balance, topup.Amount})
+	}
+	rows = append(rows, []string{"TOTAL", "", "", self.Total})
+	if self.AboveMaxTotal != "" {
+		rows = append(rows, []string{"ABOVE MAX TOTAL", "", "", self.AboveMaxTotal})
+	}
+	return rows
+}
+
+type ContractValue struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+func formatContractValues(values []ContractValue) string {
+	items := make([]string, len(values))
+	for i, v := range values {
+		items[i] = v.Value
+		if v.Name != "" {
+			items[i] = v.Name + ":" + v.Value
+		}
+	}
+	return strings.Join(items, ", ")
+}
+
+type ContractCallResult struct {
+	Method string          `json:"method"`
+	Values []ContractValue `json:"values"`
+}
+
+func (self ContractCallResult) Text() string {
+	return self.Method + " => " + formatContractValues(self.Values)
+}
+
+func (self ContractCallResult) Table() [][]string {
+	rows := [][]string{{"NAME", "TYPE", "VALUE"}}
+	for _, v := range self.Values {
+		rows = append(rows, []string{v.Name, v.Type, v.Value})
+	}
+	return rows
+}
+
+type ContractEvent struct {
+	Address common.Address `json:"address"`
+	// Name is the event ID for events that aren't in the ABIs.
+	Name   string          `json:"name"`
+	Values []ContractValue `json:"values"`
+}
+
+type ContractEventsResult struct {
+	Events []ContractEvent `json:"events"`
+}
+
+func (self ContractEventsResult) Text() string {
+	lines := make([]string, len(self.Events))
+	for i, e := range self.Events {
+		lines[i] = fmt.Sprintf("event %v %v(%v)", e.Address, e.Name, formatContractValues(e.Values))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (self ContractEventsResult) Table() [][]string {
+	rows := [][]string{{"ADDRESS", "EVENT", "VALUES"}}
+	for _, e := range self.Events {
+		rows = append(rows, []string{e.Address.Hex(), e.Name, formatContractValues(e.Values)})
+	}
+	return rows
+}
+
+type ContractAbiResult struct {
+	Address common.Address `json:"address"`
+	Methods []string       `json:"methods"`
+	Events  []string       `json:"events"`
+	Errors  []string       `json:"errors"`
+}
+
+func (self ContractAbiResult) Text() string {
+	lines := []string{"ABI of " + self.Address.Hex()}
+	lines = append(lines, self.Methods...)
+	lines = append(lines, self.Events...)
+	lines = append(lines, self.Errors...)
+	return strings.Join(lines, "\n")
+}
+
+func (self ContractAbiResult) Table() [][]string {
+	rows := [][]string{{"KIND", "SIGNATURE"}}
+	for _, m := range self.Methods {
+		rows = append(rows, []string{"method", m})
+	}
+	for _, e := range self.Events {
+		rows = append(rows, []string{"event", e})
+	}
+	for _, e := range self.Errors {
+		rows = append(rows, []string{"error", e})
+	}
+	return rows
+}
+
+type SignatureResult struct {
+	Signer    common.Address `json:"signer"`
+	Hash      common.Hash    `json:"hash"`
+	Signature hexutil.Bytes  `json:"signature"`
+}
+
+func (self SignatureResult) Text() string {
+	return fmt.Sprintf("Signed by %v hash %v signature %v", self.Signer, self.Hash, self.Signature)
+}
+
+func (self SignatureResult) Table() [][]string {
+	return [][]string{
+		{"SIGNER", "HASH", "SIGNATURE"},
+		{self.Signer.Hex(), self.Hash.Hex(), self.Signature.String()},
+	}
+}
+
+type VerifyResult struct {
+	Signer common.Address `json:"signer"`
+	Hash   common.Hash    `json:"hash"`
+	// EnvAccount is whether the signer is an account of the env.
+	EnvAccount bool     `json:"envAccount"`
+	Tags       []string `json:"tags,omitempty"`
+}
+
+func (self VerifyResult) Text() string {
+	if !self.EnvAccount {
+		return fmt.Sprintf("Signed by %v which isn't an env account", self.Signer)
+	}
+	return fmt.Sprintf("Signed by env account %v tags %v", self.Signer, strings.Join(self.Tags, ","))
+}
+
+func (self VerifyResult) Table() [][]string {
+	return [][]string{
+		{"SIGNER", "HASH", "ENV ACCOUNT", "TAGS"},
+		{self.Signer.Hex(), self.Hash.Hex(), strconv.FormatBool(self.EnvAccount), strings.Join(self.Tags, ",")},
+	}
+}
+
+type PermitResult struct {
+	Owner   common.Address `json:"owner"`
+	Spender common.Address `json:"spender"`
+	Token   common.Address `json:"token"`
+	// Amount is in the token base units.
+	Amount    string        `json:"amount"`
+	Nonce     string        `json:"nonce"`
+	Deadline  uint64        `json:"deadline"`
+	V         uint8         `json:"v"`
+	R         common.Hash   `json:"r"`
+	S         common.Hash   `json:"s"`
+	Signature hexutil.Bytes `json:"signature"`
+	Permit2   bool          `json:"permit2"`
+}
+
+func (self PermitResult) Text() string {
+	kind := "EIP-2612"
+	if self.Permit2 {
+		kind = "Permit2"
+	}
+	return fmt.Sprintf("%v permit of %v owner %v spender %v amount %v nonce %v deadline %v\nv %v r %v s %v\nsignature %v",
+		kind, self.Token, self.Owner, self.Spender, self.Amount, self.Nonce, self.Deadline, self.V, self.R, self.S, self.Signature)
+}
+
+func (self PermitResult) Table() [][]string {
+	return [][]string{
+		{"TOKEN", "OWNER", "SPENDER", "AMOUNT", "NONCE", "DEADLINE", "V", "R", "S", "SIGNATURE"},
+		{
+			self.Token.Hex(), self.Owner.Hex(), self.Spender.Hex(), self.Amount, self.Nonce, strconv.FormatUint(self.Deadline, 10),
+			strconv.Itoa(int(self.V)), self.R.Hex(), self.S.Hex(), self.Signature.String(),
+		},
+	}
+}
+
+type Allowance struct {
+	// Number is used to select the allowances to revoke.
+	Number    int            `json:"number"`
+	Owner     common.Address `json:"owner"`
+	Token     common.Address `json:"token"`
+	TokenName string         `json:"tokenName"`
+	Spender   common.Address `json:"spender"`
+	Amount    string         `json:"amount"`
+	Unlimited bool           `json:"unlimited"`
+}
+
+type AllowancesResult struct {
+	Allowances []Allowance `json:"allowances"`
+}
+
+func (self AllowancesResult) Text() string {
+	if len(self.Allowances) == 0 {
+		return "No outstanding allowances"
+	}
+	lines := make([]string, len(self.Allowances))
+	for i, a := range self.Allowances {
+		amount := a.Amount
+		if a.Unlimited {
+			amount = "UNLIMITED"
+		}
+		lines[i] = fmt.Sprintf("%v. %v owner %v spender %v amount %v", a.Number, a.TokenName, a.Owner, a.Spender, amount)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (self AllowancesResult) Table() [][]string {
+	rows := [][]string{{"NUMBER", "TOKEN", "OWNER", "SPENDER", "AMOUNT", "UNLIMITED"}}
+	for _, a := range self.Allowances {
+		rows = append(rows, []string{strconv.Itoa(a.Number), a.TokenName, a.Owner.Hex(), a.Spender.Hex(), a.Amount, strconv.FormatBool(a.Unlimited)})
+	}
+	return rows
+}
+
+type TokenInfo struct {
+	Symbol   string         `json:"symbol"`
+	Name     string         `json:"name"`
+	Decimals uint8          `json:"decimals"`
+	ChainID  int64          `json:"chainId"`
+	Address  common.Address `json:"address"`
+	Tags     []string       `json:"tags,omitempty"`
+}
+
+type TokensResult struct {
+	Tokens []TokenInfo `json:"tokens"`
+}
+
+func (self TokensResult) Text() string {
+	if len(self.Tokens) == 0 {
+		return "No custom tokens"
+	}
+	lines := make([]string, len(self.Tokens))
+	for i, t := range self.Tokens {
+		lines[i] = fmt.Sprintf("%v (%v) chain %v address %v decimals %v", t.Symbol, t.Name, t.ChainID, t.Address, t.Decimals)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (self TokensResult) Table() [][]string {
+	rows := [][]string{{"SYMBOL", "NAME", "CHAIN", "ADDRESS", "DECIMALS"}}
+	for _, t := range self.Tokens {
+		rows = append(rows, []string{t.Symbol, t.Name, strconv.FormatInt(t.ChainID, 10), t.Address.Hex(), strconv.Itoa(int(t.Decimals))})
+	}
+	return rows
+}
+
+type NftHolding struct {
+	Owner    common.Address `json:"owner"`
+	Contract common.Address `json:"contract"`
+	Standard string         `json:"standard"`
+	ID       string         `json:"id"`
+	Amount   string         `json:"amount"`
+}
+
+type NftHoldingsResult struct {
+	Holdings []NftHolding `json:"holdings"`
+}
+
+func (self NftHoldingsResult) Text() string {
+	if len(self.Holdings) == 0 {
+		return "No NFTs"
+	}
+	lines := make([]string, len(self.Holdings))
+	for i, h := range self.Holdings {
+		lines[i] = fmt.Sprintf("%v %v id %v amount %v owner %v", h.Standard, h.Contract, h.ID, h.Amount, h.Owner)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (self NftHoldingsResult) Table() [][]string {
+	rows := [][]string{{"OWNER", "CONTRACT", "STANDARD", "ID", "AMOUNT"}}
+	for _, h := range self.Holdings {
+		rows = append(rows, []string{h.Owner.Hex(), h.Contract.Hex(), h.Standard, h.ID, h.Amount})
+	}
+	return rows
+}
+
+const (
+	ReceiptSuccess  = "success"
+	ReceiptReverted = "reverted"
+)
+
+type ReceiptResult struct {
+	Hash      common.Hash `json:"hash"`
+	Status    string      `json:"status"`
+	Block     uint64      `json:"block"`
+	BlockHash common.Hash `json:"blockHash"`
+	GasUsed   uint64      `json:"gasUsed"`
+	// EffectiveGasPrice is in wei.
+	EffectiveGasPrice string `json:"effectiveGasPrice"`
+	Confirmations     uint64 `json:"confirmations"`
+}
+
+func (self ReceiptResult) Text() string {
+	price, _ := new(big.Int).SetString(self.EffectiveGasPrice, 10)
+	return fmt.Sprintf("Tx %v %v in block %v gas used %v effective gas price %v gwei confirmations %v",
+		self.Hash, self.Status, self.Block, self.GasUsed, FormatAmount(price, gweiDecimals), self.Confirmations)
+}
+
+func (self ReceiptResult) Table() [][]string {
+	return [][]string{
+		{"HASH", "STATUS", "BLOCK", "GAS USED", "EFFECTIVE GAS PRICE", "CONFIRMATIONS"},
+		{
+			self.Hash.Hex(),
+			self.Status,
+			strconv.FormatUint(self.Block, 10),
+			strconv.FormatUint(self.GasUsed, 10),
+			self.EffectiveGasPrice,
+			strconv.FormatUint(self.Confirmations, 10),
+		},
+	}
+}
+
+type Balance struct {
+	Address common.Address `json:"address"`
+	Tags    []string       `json:"tags"`
+	// Raw is the balance in the token base units.
+	Raw       string `json:"raw"`
+	Formatted string `json:"formatted"`
+}
+
+type BalancesResult struct {
+	Token     string    `json:"token"`
+	Accounts  []Balance `json:"accounts"`
+	Contracts []Balance `json:"contracts"`
+}
+
+func (self BalancesResult) Text() string {
+	lines := []string{"Token:" + self.Token, "Accounts"}
+	for i, b := range self.Accounts {
+		lines = append(lines, strconv.Itoa(i)+": "+b.Address.Hex()+" "+b.Formatted+" "+strings.Join(b.Tags, ","))
+	}
+	lines = append(lines, "Contracts")
+	for i, b := range self.Contracts {
+		lines = append(lines, strconv.Itoa(i)+": "+b.Address.Hex()+" "+b.Formatted+" "+strings.Join(b.Tags, ","))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (self BalancesResult) Table() [][]string {
+	rows := [][]string{{"TYPE", "ADDRESS", "BALANCE", "RAW", "TAGS"}}
+	for _, b := range self.Accounts {
+		rows = append(rows, []string{"account", b.Address.Hex(), b.Formatted + " " + self.Token, b.Raw, strings.Join(b.Tags, ",")})
+	}
+	for _, b := range self.Contracts {
+		rows = append(rows, []string{"contract", b.Address.Hex(), b.Formatted + " " + self.Token, b.Raw, strings.Join(b.Tags, ",")})
+	}
+	return rows
+}
+
+type NewAccount struct {
+	Address common.Address `json:"address"`
+	Priv    string         `json:"priv"`
+	// Path is the HD derivation path when derived from a mnemonic.
+	Path string `json:"path,omitempty"`
+}
+
+type AccountsResult struct {
+	Accounts []NewAccount `json:"accounts"`
+}
+
+func (self AccountsResult) Text() string {
+	var lines []string
+	for _, acc := range self.Accounts {
+		lines = append(lines, strings.TrimSpace(acc.Address.Hex()+" "+acc.Priv+" "+acc.Path))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (self AccountsResult) Table() [][]string {
+	rows := [][]string{{"ADDRESS", "PRIV", "PATH"}}
+	for _, acc := range self.Accounts {
+		rows = append(rows, []string{acc.Address.Hex(), acc.Priv, acc.Path})
+	}
+	return rows
+}
+
+// EnvResult is the output of the env export where the json schema is the env file itself.
+type EnvResult struct {
+	env.Env
+}
+
+func (self EnvResult) Text() string {
+	content, err := json.MarshalIndent(self.Env, "", "    ")
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return string(content)
+}
+
+func (self EnvResult) Table() [][]string {
+	rows := [][]string{{"TYPE", "VALUE", "TAGS"}}
+	for _, node := range self.Nodes {
+		rows = append(rows, []string{"node", node.URL, ""})
+	}
+	for _, key := range self.ApiKeys {
+		rows = append(rows, []string{"api key", key.Value, strings.Join(key.Tags, ",")})
+	}
+	for _, acc := range self.Accounts {
+		rows = append(rows, []string{"account", acc.Pub.Hex() + " " + acc.Priv, strings.Join(acc.Tags, ",")})
+	}
+	for _, contract := range self.Contracts {
+		rows = append(rows, []string{"contract", contract.Address.Hex(), strings.Join(contract.Tags, ",")})
+	}
+	return rows
+}
+
+// ValueResult is the output of commands that produce a single string like
+// a mnemonic or an encrypted input.
+type ValueResult struct {
+	Value string `json:"value"`
+}
+
+func (self ValueResult) Text() string {
+	return self.Value
+}
+
+func (self ValueResult) Table() [][]string {
+	return [][]string{{"VALUE"}, {self.Value}}
+}
+
+type MnemonicResult struct {
+	Mnemonic string `json:"mnemonic"`
+	// Checksum is the bits of the entropy hash included in the last word.
+	Checksum string `json:"checksum"`
+	// Path and Address are of the first derived account to verify
+	// that the mnemonic was restored correctly.
+	Path    string `json:"path"`
+	Address string `json:"address"`
+}
+
+func (self MnemonicResult) Text() string {
+	return self.Mnemonic + "\n" +
+		"checksum: " + self.Checksum + "\n" +
+		"first address " + self.Path + ": " + self.Address
+}
+
+func (self MnemonicResult) Table() [][]string {
+	return [][]string{
+		{"MNEMONIC", "CHECKSUM", "PATH", "ADDRESS"},
+		{self.Mnemonic, self.Checksum, self.Path, self.Address},
+	}
+}
+
+type TokenBalance struct {
+	Token     string `json:"token"`
+	Raw       string `json:"raw"`
+	Formatted string `json:"formatted"`
+}
+
+type DiscoveredAccount struct {
+	Path     string         `json:"path"`
+	Address  common.Address `json:"address"`
+	Nonce    uint64         `json:"nonce"`
+	Balances []TokenBalance `json:"balances"`
+}
+
+type DiscoverResult struct {
+	Accounts []DiscoveredAccount `json:"accounts"`
+}
+
+func (self DiscoverResult) Text() string {
+	if len(self.Accounts) == 0 {
+		return "no used accounts found"
+	}
+	var lines []string
+	for _, acc := range self.Accounts {
+		var balances []string
+		for _, b := range acc.Balances {
+			balances = append(balances, b.Formatted+" "+b.Token)
+		}
+		lines = append(lines, acc.Path+" "+acc.Address.Hex()+" nonce:"+strconv.FormatUint(acc.Nonce, 10)+" "+strings.Join(balances, ", "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (self DiscoverResult) Table() [][]string {
+	rows := [][]string{{"PATH", "ADDRESS", "NONCE", "BALANCES"}}
+	for _, acc := range self.Accounts {
+		var balances []string
+		for _, b := range acc.Balances {
+			balances = append(balances, b.Formatted+" "+b.Token)
+		}
+		rows = append(rows, []string{acc.Path, acc.Address.Hex(), strconv.FormatUint(acc.Nonce, 10), strings.Join(balances, ", ")})
+	}
+	return rows
+}
+
+type KeystoreAccount struct {
+	Address common.Address `json:"address"`
+	File    string         `json:"file"`
+}
+
+type KeystoreResult struct {
+	Accounts []KeystoreAccount `json:"accounts"`
+}
+
+func (self KeystoreResult) Text() string {
+	var lines []string
+	for _, acc := range self.Accounts {
+		lines = append(lines, acc.Address.Hex()+" "+acc.File)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (self KeystoreResult) Table() [][]string {
+	rows := [][]string{{"ADDRESS", "FILE"}}
+	for _, acc := range self.Accounts {
+		rows = append(rows, []string{acc.Address.Hex(), acc.File})
+	}
+	return rows
+}
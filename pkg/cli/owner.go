@@ -3,35 +3,35 @@ package cli
 import (
 	"context"
 	"fmt"
-	"strings"
 
 	client_p "github.com/cryptoriums/packages/client"
 	"github.com/cryptoriums/packages/contracts/bindings/interfaces"
 	"github.com/cryptoriums/packages/env"
 	"github.com/cryptoriums/packages/prompt"
-	tx_p "github.com/cryptoriums/packages/tx"
-
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/go-kit/log"
 	"github.com/pkg/errors"
 )
 
-type SetOwnerCmd struct{}
+type SetOwnerCmd struct {
+	SetOwnerFlags
+	Gas
+}
+
+// SetOwnerFlags are shared with the command that builds the TX for offline signing.
+type SetOwnerFlags struct {
+	From     string  `optional:"" env:"WALLGER_FROM" help:"current owner address, prompted for when not set"`
+	To       string  `optional:"" env:"WALLGER_TO" help:"new owner address, prompted for when not set"`
+	Contract string  `optional:"" help:"contract address, prompted for when not set"`
+	Nonce    *uint64 `optional:"" help:"TX nonce, prompted for when not set"`
+}
 
 func (self *SetOwnerCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
-	_, filePath, err := prompt.ReadFile()
+	envr, err := cli.Load("enter tags separated by a comma: ")
 	if err != nil {
 		return err
 	}
-	_tags, err := prompt.PromptInput("enter tags separated by a comma: ")
-	if err != nil {
-		return errors.Wrap(err, "prompt tags")
-	}
-	tags := strings.Split(_tags, ",")
-
-	envr, err := env.LoadFromFile(filePath, tags...)
-	if err != nil {
-		return errors.Wrap(err, "loading env from file")
-	}
 
 	client, err := client_p.NewClientCachedNetID(ctx, logger, envr.Nodes[0].URL)
 	if err != nil {
@@ -39,29 +39,24 @@ func (self *SetOwnerCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) e
 	}
 
 	for {
-		currentOwner, pass, err := env.SelectAccountAndDecrypt(envr.Accounts, false, "Select current owner's pub address:")
+		currentOwner, pass, err := self.selectSender(envr.Accounts, self.From, false, "Select current owner's pub address:")
 		if err != nil {
-			return errors.Wrap(err, "SelectAccountAndDecrypt sender")
+			return errors.Wrap(err, "select current owner")
 		}
 
-		if env.IsEncryptedEnv(envr) {
+		if pass != "" && env.IsEncryptedEnv(envr) {
 			envr, err = env.DecryptEnv(envr, pass)
 			if err != nil {
 				return errors.Wrap(err, "DecryptEnv")
 			}
 		}
 
-		currentOwnerAcc, err := tx_p.AccountFromPrvKey(currentOwner.Priv)
-		if err != nil {
-			return errors.Wrap(err, "AccountFromPrvKey")
-		}
-
-		newOwner, err := env.SelectAccount(envr.Accounts, false, "Select new owner's pub address:")
+		newOwner, err := selectAccount(envr.Accounts, self.To, false, "Select new owner's pub address:")
 		if err != nil {
 			return errors.Wrap(err, "SelectAccount receiver")
 		}
 
-		conract, _, err := prompt.Contract(envr.Contracts, false, false)
+		conract, err := selectContract(envr.Contracts, self.Contract)
 		if err != nil {
 			return errors.Wrap(err, "selectProxy")
 		}
@@ -71,30 +66,40 @@ func (self *SetOwnerCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) e
 			return errors.Wrap(err, "NewIERC20")
 		}
 
-		nonce, err := prompt.Nonce(ctx, client, currentOwner.Pub)
-		if err != nil {
-			return errors.Wrap(err, "selectNonce")
+		var nonce uint64
+		if self.Nonce != nil {
+			nonce = *self.Nonce
+			// Another run would replace the TX with the same nonce.
+			self.Nonce = nil
+		} else {
+			nonce, err = prompt.Nonce(ctx, client, currentOwner.Pub)
+			if err != nil {
+				return errors.Wrap(err, "selectNonce")
+			}
 		}
 
-		gasPrice, err := prompt.Float("enter TX gas price(gwei): ", 0, 300)
+		fees, err := self.Fees(ctx, client, cli.Yes)
 		if err != nil {
 			return err
 		}
 
-		opts, err := tx_p.NewTxOpts(ctx, client, nonce, currentOwnerAcc, gasPrice, gasPrice, 150_000)
+		data, err := packCall(func(opts *bind.TransactOpts) (*types.Transaction, error) {
+			return ownable.SetOwner(opts, newOwner.Pub)
+		})
 		if err != nil {
-			return errors.Wrap(err, "NewTxOpts")
+			return errors.Wrap(err, "pack SetOwner")
 		}
-		tx, err := ownable.SetOwner(opts, newOwner.Pub)
+
+		err = self.sendTx(ctx, cli, client, currentOwner, nonce, fees, *conract, nil, data,
+			fmt.Sprintf("owner change of:%v from:%v, to:%v", *conract, currentOwner.Pub, newOwner.Pub),
+		)
 		if err != nil {
-			return errors.Wrap(err, "Transfer")
+			return err
 		}
 
-		fmt.Println("Tx Created", "nonce", nonce, "hash", tx.Hash())
-
-		anotherRun, err := prompt.PromptConfirm("Another run?")
+		anotherRun, err := self.anotherRun(cli)
 		if err != nil {
-			return errors.Wrap(err, "prompt for another run")
+			return err
 		}
 		if !anotherRun {
 			break
@@ -0,0 +1,433 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	client_p "github.com/cryptoriums/packages/client"
+	"github.com/cryptoriums/packages/prompt"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// permit2Address is the Uniswap Permit2 contract which has the same address on all chains.
+var permit2Address = common.HexToAddress("0x000000000022D473030F116dDEE9F6B43aC78BA3")
+
+var maxUint160 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 160), big.NewInt(1))
+
+const permit2ABI = `[{"type":"function","name":"permit","stateMutability":"nonpayable","outputs":[],"inputs":[
+	{"name":"owner","type":"address"},
+	{"name":"permitSingle","type":"tuple","components":[
+		{"name":"details","type":"tuple","components":[
+			{"name":"token","type":"address"},
+			{"name":"amount","type":"uint160"},
+			{"name":"expiration","type":"uint48"},
+			{"name":"nonce","type":"uint48"}
+		]},
+		{"name":"spender","type":"address"},
+		{"name":"sigDeadline","type":"uint256"}
+	]},
+	{"name":"signature","type":"bytes"}
+]}]`
+
+// permitDetails and permitSingle are the Permit2 structs packed by the ABI encoder.
+type permitDetails struct {
+	Token      common.Address
+	Amount     *big.Int
+	Expiration *big.Int
+	Nonce      *big.Int
+}
+
+type permitSingle struct {
+	Details     permitDetails
+	Spender     common.Address
+	SigDeadline *big.Int
+}
+
+type TokenPermitCmd struct {
+	Token               string        `optional:"" help:"token contract address, prompted for when not set"`
+	From                string        `optional:"" env:"WALLGER_FROM" help:"token owner address that signs the permit, prompted for when not set"`
+	To                  string        `optional:"" env:"WALLGER_TO" help:"spender address, prompted for when not set"`
+	Amount              string        `optional:"" help:"approve limit in token units, max or unlimited, prompted for when not set"`
+	Raw                 bool          `optional:"" help:"the approve limit is in token base units"`
+	Deadline            time.Duration `default:"1h" help:"time from now after which the signature expires"`
+	Version             string        `optional:"" help:"EIP-712 domain version of the token, read from the token or 1 when not set"`
+	Permit2             bool          `optional:"" name:"permit2" help:"sign a Uniswap Permit2 PermitSingle instead of an EIP-2612 permit"`
+	Expiration          time.Duration `default:"720h" help:"time from now after which the Permit2 allowance expires"`
+	SubmitFrom          string        `optional:"" help:"account that pays the gas to submit the permit, the signature is only printed when not set"`
+	Nonce               *uint64       `optional:"" help:"nonce of the submit TX, prompted for when not set"`
+	AllowUnknownSpender bool          `optional:"" help:"allow unlimited permits to spenders that aren't env contracts in non interactive runs"`
+	Gas
+}
+
+func (self *TokenPermitCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	e, err := cli.Load("enter tags separated by a comma: ")
+	if err != nil {
+		return err
+	}
+
+	client, err := client_p.NewClientCachedNetID(ctx, logger, e.Nodes[0].URL)
+	if err != nil {
+		return errors.Wrap(err, "NewClientCachedNetID")
+	}
+
+	token, err := selectToken(ctx, cli, client, client.NetworkID(), self.Token)
+	if err != nil {
+		return errors.Wrap(err, "selectToken")
+	}
+	if token.IsETH() {
+		return errors.New("ETH doesn't support permits")
+	}
+
+	// The owner key is decrypted only after the permit is confirmed.
+	owner, err := selectEnvAccount(e.Accounts, self.From, "Select owner's pub address:")
+	if err != nil {
+		return errors.Wrap(err, "select owner")
+	}
+
+	spenderInput := self.To
+	if spenderInput == "" {
+		spenderInput, err = prompt.PromptInput("Select spender address: ")
+		if err != nil {
+			return errors.Wrap(err, "spender prompt")
+		}
+	}
+	spender, err := parseAddress(spenderInput)
+	if err != nil {
+		return errors.Wrap(err, "spender")
+	}
+
+	// Permit2 amounts are uint160 and the max is unlimited.
+	max := maxUint256
+	if self.Permit2 {
+		max = maxUint160
+	}
+	amount, err := selectApproveAmount(logger, self.Amount, self.Raw, token, token.Name+" permit limit or max: ", max)
+	if err != nil {
+		return errors.Wrap(err, "select amount")
+	}
+	if amount.Cmp(unlimitedAllowance) >= 0 {
+		err = confirmUnlimited(cli, e.Contracts, spender, self.AllowUnknownSpender)
+		if err != nil {
+			return err
+		}
+	}
+
+	deadline := big.NewInt(time.Now().Add(self.Deadline).Unix())
+
+	var hash []byte
+	var breakdown string
+	var single permitSingle
+	var nonce *big.Int
+	if self.Permit2 {
+		values, err := callMethod(ctx, client, permit2Address, "allowance(address,address,address) returns (uint160,uint48,uint48)", owner.Pub, token.Address, spender)
+		if err != nil {
+			return errors.Wrap(err, "Permit2 allowance")
+		}
+		nonce = values[2].(*big.Int)
+
+		values, err = callMethod(ctx, client, token.Address, "allowance(address,address) returns (uint256)", owner.Pub, permit2Address)
+		if err != nil {
+			return errors.Wrap(err, "token allowance of Permit2")
+		}
+		if values[0].(*big.Int).Cmp(amount) < 0 {
+			level.Warn(logger).Log("msg", "the owner hasn't approved Permit2 for the amount so the spender can't transfer it yet")
+		}
+
+		single = permitSingle{
+			Details: permitDetails{
+				Token:      token.Address,
+				Amount:     amount,
+				Expiration: big.NewInt(time.Now().Add(self.Expiration).Unix()),
+				Nonce:      nonce,
+			},
+			Spender:     spender,
+			SigDeadline: deadline,
+		}
+		hash = permit2Hash(client.NetworkID(), single)
+		breakdown = fmt.Sprintf("Permit2 domain chain:%v, contract:%v\nPermitSingle token:%v, amount:%v, expiration:%v, nonce:%v, spender:%v, sig deadline:%v\n",
+			client.NetworkID(), permit2Address, token.Address, amount, single.Details.Expiration, nonce, spender, deadline,
+		)
+	} else {
+		values, err := callMethod(ctx, client, token.Address, "nonces(address) returns (uint256)", owner.Pub)
+		if err != nil {
+			return errors.Wrap(err, "token doesn't support EIP-2612 permits, nonces")
+		}
+		nonce = values[0].(*big.Int)
+
+		domain, domainType, err := self.tokenDomain(ctx, client, client.NetworkID(), token.Address)
+		if err != nil {
+			return err
+		}
+		typedData := permitTypedData(domain, domainType, owner.Pub, spender, amount, nonce, deadline)
+		hash, _, err = apitypes.TypedDataAndHash(typedData)
+		if err != nil {
+			return errors.Wrap(err, "hash typed data")
+		}
+		formatted, err := typedData.Format()
+		if err != nil {
+			return errors.Wrap(err, "format typed data")
+		}
+		var b strings.Builder
+		for _, nvt := range formatted {
+			formatTypedValue(&b, nvt, 0)
+		}
+		breakdown = b.String()
+	}
+
+	err = cli.Confirm(breakdown + fmt.Sprintf("Sign permit of:%v amount:%v for spender:%v?", token.Name, formatApproveAmount(amount, token.Decimals, max), spender))
+	if err != nil {
+		return err
+	}
+
+	owner, _, err = decryptAccount(owner)
+	if err != nil {
+		return errors.Wrap(err, "decrypt owner")
+	}
+	_, sig, err := signDigest(owner, hash)
+	if err != nil {
+		return err
+	}
+	err = cli.Print(PermitResult{
+		Owner:     owner.Pub,
+		Spender:   spender,
+		Token:     token.Address,
+		Amount:    amount.String(),
+		Nonce:     nonce.String(),
+		Deadline:  deadline.Uint64(),
+		V:         sig[crypto.RecoveryIDOffset],
+		R:         common.BytesToHash(sig[:32]),
+		S:         common.BytesToHash(sig[32:64]),
+		Signature: sig,
+		Permit2:   self.Permit2,
+	})
+	if err != nil {
+		return err
+	}
+
+	if self.SubmitFrom == "" {
+		return nil
+	}
+
+	payer, _, err := selectAccountAndDecrypt(e.Accounts, self.SubmitFrom, false, "")
+	if err != nil {
+		return errors.Wrap(err, "select gas payer")
+	}
+
+	to, data := token.Address, []byte(nil)
+	if self.Permit2 {
+		to = permit2Address
+		data, err = packPermit2(owner.Pub, single, sig)
+	} else {
+		data, err = packMethod("permit(address,address,uint256,uint256,uint8,bytes32,bytes32)",
+			owner.Pub, spender, amount, deadline, sig[crypto.RecoveryIDOffset], common.BytesToHash(sig[:32]), common.BytesToHash(sig[32:64]),
+		)
+	}
+	if err != nil {
+		return err
+	}
+
+	fees, err := self.Fees(ctx, client, cli.Yes)
+	if err != nil {
+		return err
+	}
+
+	var txNonce uint64
+	if self.Nonce != nil {
+		txNonce = *self.Nonce
+	} else {
+		txNonce, err = prompt.Nonce(ctx, client, payer.Pub)
+		if err != nil {
+			return errors.Wrap(err, "selectNonce")
+		}
+	}
+
+	return self.sendTx(ctx, cli, client, payer, txNonce, fees, to, nil, data,
+		fmt.Sprintf("permit of:%v owner:%v, spender:%v, amount:%v, paid by:%v", token.Name, owner.Pub, spender, formatApproveAmount(amount, token.Decimals, max), payer.Pub),
+	)
+}
+
+// tokenDomain returns the EIP-712 domain of the token and its type
+// read with the ERC-5267 eip712Domain or built from the token name and version
+// and checks it against the token DOMAIN_SEPARATOR.
+func (self *TokenPermitCmd) tokenDomain(ctx context.Context, client bind.ContractCaller, netID int64, tokenAddr common.Address) (apitypes.TypedDataDomain, []apitypes.Type, error) {
+	var domain apitypes.TypedDataDomain
+	domainType := eip712DomainType
+	if values, err := callMethod(ctx, client, tokenAddr, "eip712Domain() returns (bytes1,string,string,uint256,address,bytes32,uint256[])"); err == nil {
+		domain, domainType, err = erc5267Domain(values, netID, tokenAddr)
+		if err != nil {
+			return apitypes.TypedDataDomain{}, nil, err
+		}
+		if self.Version != "" && self.Version != domain.Version {
+			return apitypes.TypedDataDomain{}, nil, errors.Errorf("version:%v doesn't match the token eip712Domain version:%v", self.Version, domain.Version)
+		}
+	} else {
+		values, err := callMethod(ctx, client, tokenAddr, "name() returns (string)")
+		if err != nil {
+			return apitypes.TypedDataDomain{}, nil, errors.Wrap(err, "token name")
+		}
+		name := values[0].(string)
+
+		version := self.Version
+		if version == "" {
+			version = "1"
+			// Only some tokens expose the version.
+			if values, err := callMethod(ctx, client, tokenAddr, "version() returns (string)"); err == nil {
+				version = values[0].(string)
+			}
+		}
+
+		domain = apitypes.TypedDataDomain{
+			Name:              name,
+			Version:           version,
+			ChainId:           math.NewHexOrDecimal256(netID),
+			VerifyingContract: tokenAddr.Hex(),
+		}
+	}
+
+	values, err := callMethod(ctx, client, tokenAddr, "DOMAIN_SEPARATOR() returns (bytes32)")
+	if err != nil {
+		return apitypes.TypedDataDomain{}, nil, errors.Wrap(err, "token DOMAIN_SEPARATOR")
+	}
+	separator := common.Hash(values[0].([32]byte))
+
+	typedData := apitypes.TypedData{Types: apitypes.Types{"EIP712Domain": domainType}, Domain: domain}
+	computed, err := typedData.HashStruct("EIP712Domain", domain.Map())
+	if err != nil {
+		return apitypes.TypedDataDomain{}, nil, errors.Wrap(err, "hash domain")
+	}
+	if common.BytesToHash(computed) != separator {
+		return apitypes.TypedDataDomain{}, nil, errors.Errorf("token DOMAIN_SEPARATOR:%v doesn't match the domain name:%v version:%v chain:%v contract:%v with hash:%v, set the version with --version when the token doesn't expose it",
+			separator, domain.Name, domain.Version, netID, tokenAddr, common.BytesToHash(computed),
+		)
+	}
+	return domain, domainType, nil
+}
+
+// erc5267Domain returns the domain and its type from the eip712Domain values
+// after checking that it is the domain of the token on the node chain.
+func erc5267Domain(values []interface{}, netID int64, tokenAddr common.Address) (apitypes.TypedDataDomain, []apitypes.Type, error) {
+	fields := values[0].([1]byte)[0]
+	// Salt and extensions aren't used by the known tokens.
+	if fields&^0x0f != 0 || len(values[6].([]*big.Int)) > 0 {
+		return apitypes.TypedDataDomain{}, nil, errors.Errorf("unsupported token eip712Domain fields:%#x", fields)
+	}
+
+	var domain apitypes.TypedDataDomain
+	var domainType []apitypes.Type
+	for i, field := range eip712DomainType {
+		if fields&(1<<i) == 0 {
+			continue
+		}
+		domainType = append(domainType, field)
+		switch field.Name {
+		case "name":
+			domain.Name = values[1].(string)
+		case "version":
+			domain.Version = values[2].(string)
+		case "chainId":
+			chainID := values[3].(*big.Int)
+			if chainID.Cmp(big.NewInt(netID)) != 0 {
+				return apitypes.TypedDataDomain{}, nil, errors.Errorf("token eip712Domain chain:%v isn't the node chain:%v", chainID, netID)
+			}
+			domain.ChainId = math.NewHexOrDecimal256(netID)
+		case "verifyingContract":
+			contract := values[4].(common.Address)
+			if contract != tokenAddr {
+				return apitypes.TypedDataDomain{}, nil, errors.Errorf("token eip712Domain contract:%v isn't the token:%v", contract, tokenAddr)
+			}
+			domain.VerifyingContract = contract.Hex()
+		}
+	}
+	return domain, domainType, nil
+}
+
+// eip712DomainType has the ERC-5267 field order.
+var eip712DomainType = []apitypes.Type{
+	{Name: "name", Type: "string"},
+	{Name: "version", Type: "string"},
+	{Name: "chainId", Type: "uint256"},
+	{Name: "verifyingContract", Type: "address"},
+}
+
+func permitTypedData(domain apitypes.TypedDataDomain, domainType []apitypes.Type, owner, spender common.Address, amount, nonce, deadline *big.Int) apitypes.TypedData {
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": domainType,
+			"Permit": {
+				{Name: "owner", Type: "address"},
+				{Name: "spender", Type: "address"},
+				{Name: "value", Type: "uint256"},
+				{Name: "nonce", Type: "uint256"},
+				{Name: "deadline", Type: "uint256"},
+			},
+		},
+		PrimaryType: "Permit",
+		Domain:      domain,
+		Message: apitypes.TypedDataMessage{
+			"owner":    owner.Hex(),
+			"spender":  spender.Hex(),
+			"value":    amount.String(),
+			"nonce":    nonce.String(),
+			"deadline": deadline.String(),
+		},
+	}
+}
+
+const (
+	permitDetailsType = "PermitDetails(address token,uint160 amount,uint48 expiration,uint48 nonce)"
+	permitSingleType  = "PermitSingle(PermitDetails details,address spender,uint256 sigDeadline)" + permitDetailsType
+)
+
+// permit2Hash returns the EIP-712 hash of the PermitSingle.
+// It is computed directly since the go-ethereum typed data supports only some integer sizes.
+func permit2Hash(netID int64, permit permitSingle) []byte {
+	word := func(v *big.Int) []byte { return common.LeftPadBytes(v.Bytes(), 32) }
+	addr := func(a common.Address) []byte { return common.LeftPadBytes(a.Bytes(), 32) }
+
+	domainSeparator := crypto.Keccak256(
+		crypto.Keccak256([]byte("EIP712Domain(string name,uint256 chainId,address verifyingContract)")),
+		crypto.Keccak256([]byte("Permit2")),
+		word(big.NewInt(netID)),
+		addr(permit2Address),
+	)
+	details := crypto.Keccak256(
+		crypto.Keccak256([]byte(permitDetailsType)),
+		addr(permit.Details.Token),
+		word(permit.Details.Amount),
+		word(permit.Details.Expiration),
+		word(permit.Details.Nonce),
+	)
+	single := crypto.Keccak256(
+		crypto.Keccak256([]byte(permitSingleType)),
+		details,
+		addr(permit.Spender),
+		word(permit.SigDeadline),
+	)
+	return crypto.Keccak256([]byte("\x19\x01"), domainSeparator, single)
+}
+
+// packPermit2 encodes the Permit2 permit call with the signed PermitSingle.
+func packPermit2(owner common.Address, permit permitSingle, sig []byte) ([]byte, error) {
+	contractABI, err := parseABI([]byte(permit2ABI))
+	if err != nil {
+		return nil, err
+	}
+	data, err := contractABI.Pack("permit", owner, permit, sig)
+	if err != nil {
+		return nil, errors.Wrap(err, "pack Permit2 permit")
+	}
+	return data, nil
+}
@@ -0,0 +1,284 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+// methodCaller returns the output of the method signatures that it has
+// like "name() returns (string)" and reverts the calls of the other methods.
+type methodCaller map[string][]byte
+
+func (self methodCaller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (self methodCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	for sig, output := range self {
+		method, _, _ := strings.Cut(sig, " returns")
+		if bytes.HasPrefix(call.Data, crypto.Keccak256([]byte(method))[:4]) {
+			return output, nil
+		}
+	}
+	return nil, errors.New("execution reverted")
+}
+
+func packOutput(t *testing.T, sig string, values ...interface{}) []byte {
+	t.Helper()
+	parsed, err := parseSignatureABI(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range parsed.Methods {
+		output, err := method.Outputs.Pack(values...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return output
+	}
+	t.Fatalf("no method in:%v", sig)
+	return nil
+}
+
+func TestPermit2TypeHashes(t *testing.T) {
+	// The constants of the deployed Permit2 contract.
+	tests := []struct {
+		typ  string
+		want string
+	}{
+		{typ: permitDetailsType, want: "0x65626cad6cb96493bf6f5ebea28756c966f023ab9e8a83a7101849d5573b3678"},
+		{typ: permitSingleType, want: "0xf3841cd1ff0085026a6327b620b67997ce40f282c88a8e905a7a5626e310f3d0"},
+		{typ: "EIP712Domain(string name,uint256 chainId,address verifyingContract)", want: "0x8cad95687ba82c2ce50e74f7b754645e5117c3a5bec8151c0726d5857980a866"},
+	}
+	for _, tt := range tests {
+		if got := crypto.Keccak256Hash([]byte(tt.typ)).Hex(); got != tt.want {
+			t.Errorf("%v got:%v, want:%v", tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestPermit2Hash(t *testing.T) {
+	newType := func(typ string) abi.Type {
+		v, err := abi.NewType(typ, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	encode := func(types []string, values ...interface{}) []byte {
+		var args abi.Arguments
+		for _, typ := range types {
+			args = append(args, abi.Argument{Type: newType(typ)})
+		}
+		packed, err := args.Pack(values...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return crypto.Keccak256(packed)
+	}
+	typeHash := func(typ string) [32]byte { return crypto.Keccak256Hash([]byte(typ)) }
+
+	tests := []struct {
+		name   string
+		netID  int64
+		permit permitSingle
+	}{
+		{
+			name:  "mainnet",
+			netID: 1,
+			permit: permitSingle{
+				Details: permitDetails{
+					Token:      common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"),
+					Amount:     big.NewInt(1000000),
+					Expiration: big.NewInt(1700000000),
+					Nonce:      big.NewInt(0),
+				},
+				Spender:     common.HexToAddress("0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD"),
+				SigDeadline: big.NewInt(1690000000),
+			},
+		},
+		{
+			name:  "max amount",
+			netID: 137,
+			permit: permitSingle{
+				Details: permitDetails{
+					Token:      common.HexToAddress("0x2791Bca1f2de4661ED88A30C99A7a9449Aa84174"),
+					Amount:     maxUint160,
+					Expiration: big.NewInt(1800000000),
+					Nonce:      big.NewInt(7),
+				},
+				Spender:     common.HexToAddress("0x1111111254EEB25477B68fb85Ed929f73A960582"),
+				SigDeadline: big.NewInt(1800000000),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			domain := encode([]string{"bytes32", "bytes32", "uint256", "address"},
+				typeHash("EIP712Domain(string name,uint256 chainId,address verifyingContract)"), typeHash("Permit2"), big.NewInt(tt.netID), permit2Address,
+			)
+			details := encode([]string{"bytes32", "address", "uint160", "uint48", "uint48"},
+				typeHash(permitDetailsType), tt.permit.Details.Token, tt.permit.Details.Amount, tt.permit.Details.Expiration, tt.permit.Details.Nonce,
+			)
+			single := encode([]string{"bytes32", "bytes32", "address", "uint256"},
+				typeHash(permitSingleType), common.BytesToHash(details), tt.permit.Spender, tt.permit.SigDeadline,
+			)
+			want := crypto.Keccak256([]byte("\x19\x01"), domain, single)
+
+			if got := permit2Hash(tt.netID, tt.permit); !bytes.Equal(got, want) {
+				t.Fatalf("got:%x, want:%x", got, want)
+			}
+		})
+	}
+}
+
+func TestTokenDomain(t *testing.T) {
+	tokenAddr := common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F")
+	word := func(v int64) []byte { return common.LeftPadBytes(big.NewInt(v).Bytes(), 32) }
+	separator := func(withVersion bool, name, version string, netID int64) [32]byte {
+		if !withVersion {
+			return crypto.Keccak256Hash(
+				crypto.Keccak256([]byte("EIP712Domain(string name,uint256 chainId,address verifyingContract)")),
+				crypto.Keccak256([]byte(name)), word(netID), common.LeftPadBytes(tokenAddr.Bytes(), 32),
+			)
+		}
+		return crypto.Keccak256Hash(
+			crypto.Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)")),
+			crypto.Keccak256([]byte(name)), crypto.Keccak256([]byte(version)), word(netID), common.LeftPadBytes(tokenAddr.Bytes(), 32),
+		)
+	}
+	const (
+		nameSig      = "name() returns (string)"
+		versionSig   = "version() returns (string)"
+		separatorSig = "DOMAIN_SEPARATOR() returns (bytes32)"
+		domainSig    = "eip712Domain() returns (bytes1,string,string,uint256,address,bytes32,uint256[])"
+	)
+	eip712Domain := func(fields byte, name, version string, netID int64) []byte {
+		return packOutput(t, domainSig, [1]byte{fields}, name, version, big.NewInt(netID), tokenAddr, [32]byte{}, []*big.Int{})
+	}
+
+	tests := []struct {
+		name    string
+		calls   map[string][]byte
+		version string
+		want    string
+		err     bool
+	}{
+		{
+			name: "token version",
+			calls: map[string][]byte{
+				nameSig:      packOutput(t, nameSig, "Token"),
+				versionSig:   packOutput(t, versionSig, "2"),
+				separatorSig: packOutput(t, separatorSig, separator(true, "Token", "2", 1)),
+			},
+			want: "2",
+		},
+		{
+			name: "default version",
+			calls: map[string][]byte{
+				nameSig:      packOutput(t, nameSig, "Token"),
+				separatorSig: packOutput(t, separatorSig, separator(true, "Token", "1", 1)),
+			},
+			want: "1",
+		},
+		{
+			name: "version flag",
+			calls: map[string][]byte{
+				nameSig:      packOutput(t, nameSig, "Token"),
+				separatorSig: packOutput(t, separatorSig, separator(true, "Token", "3", 1)),
+			},
+			version: "3",
+			want:    "3",
+		},
+		{
+			name: "separator mismatch",
+			calls: map[string][]byte{
+				nameSig:      packOutput(t, nameSig, "Token"),
+				versionSig:   packOutput(t, versionSig, "2"),
+				separatorSig: packOutput(t, separatorSig, separator(true, "Token", "1", 1)),
+			},
+			err: true,
+		},
+		{
+			name: "eip712Domain",
+			calls: map[string][]byte{
+				domainSig:    eip712Domain(0x0f, "Token", "2", 1),
+				separatorSig: packOutput(t, separatorSig, separator(true, "Token", "2", 1)),
+			},
+			want: "2",
+		},
+		{
+			name: "eip712Domain without version",
+			calls: map[string][]byte{
+				domainSig:    eip712Domain(0x0d, "Token", "", 1),
+				separatorSig: packOutput(t, separatorSig, separator(false, "Token", "", 1)),
+			},
+			want: "",
+		},
+		{
+			name: "eip712Domain version flag mismatch",
+			calls: map[string][]byte{
+				domainSig:    eip712Domain(0x0f, "Token", "2", 1),
+				separatorSig: packOutput(t, separatorSig, separator(true, "Token", "2", 1)),
+			},
+			version: "1",
+			err:     true,
+		},
+		{
+			name: "eip712Domain other chain",
+			calls: map[string][]byte{
+				domainSig:    eip712Domain(0x0f, "Token", "2", 5),
+				separatorSig: packOutput(t, separatorSig, separator(true, "Token", "2", 5)),
+			},
+			err: true,
+		},
+		{
+			name: "eip712Domain salt",
+			calls: map[string][]byte{
+				domainSig:    eip712Domain(0x1f, "Token", "2", 1),
+				separatorSig: packOutput(t, separatorSig, separator(true, "Token", "2", 1)),
+			},
+			err: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := TokenPermitCmd{Version: tt.version}
+			domain, domainType, err := cmd.tokenDomain(context.Background(), methodCaller(tt.calls), 1, tokenAddr)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got:%v", domain)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if domain.Version != tt.want {
+				t.Fatalf("version got:%v, want:%v", domain.Version, tt.want)
+			}
+			if domain.Name != "Token" || domain.VerifyingContract != tokenAddr.Hex() {
+				t.Fatalf("unexpected domain:%+v", domain)
+			}
+			var fields []string
+			for _, field := range domainType {
+				fields = append(fields, field.Name)
+			}
+			if tt.want == "" && strings.Contains(strings.Join(fields, ","), "version") {
+				t.Fatalf("unexpected version in the domain type:%v", fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cryptoriums/packages/env"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// ContractABI is the ABI of an env contract stored in the env file.
+// The ABI is inline, a reference to an ABI file or function signatures
+// and all that are set are merged.
+type ContractABI struct {
+	ABI json.RawMessage `json:",omitempty"`
+	// File is relative to the env file directory unless it is absolute.
+	File       string   `json:",omitempty"`
+	Signatures []string `json:",omitempty"`
+}
+
+// ABIs returns the ABIs of the contracts from the env file.
+// They are loaded once and cached.
+func (self *EnvFlags) ABIs() (map[common.Address]abi.ABI, error) {
+	if self.abis != nil {
+		return self.abis, nil
+	}
+	filePath, err := self.FilePath()
+	if err != nil {
+		return nil, err
+	}
+	ext, err := loadEnvExt(filePath)
+	if err != nil {
+		return nil, err
+	}
+	abis := make(map[common.Address]abi.ABI)
+	for addr, contractABI := range ext.ContractABIs {
+		parsed, err := contractABI.Parse(filepath.Dir(filePath))
+		if err != nil {
+			return nil, errors.Wrapf(err, "ABI of contract:%v", addr)
+		}
+		abis[addr] = parsed
+	}
+	self.abis = abis
+	return abis, nil
+}
+
+// KnownABIs returns the ABIs of all env contracts with the one of the given contract first.
+func (self *EnvFlags) KnownABIs(contract common.Address) ([]abi.ABI, error) {
+	registry, err := self.ABIs()
+	if err != nil {
+		return nil, err
+	}
+	var abis []abi.ABI
+	if contractABI, ok := registry[contract]; ok {
+		abis = append(abis, contractABI)
+	}
+	for addr, contractABI := range registry {
+		if addr != contract {
+			abis = append(abis, contractABI)
+		}
+	}
+	return abis, nil
+}
+
+func (self ContractABI) Parse(dir string) (abi.ABI, error) {
+	parsed := abi.ABI{
+		Methods: make(map[string]abi.Method),
+		Events:  make(map[string]abi.Event),
+		Errors:  make(map[string]abi.Error),
+	}
+	var parts []abi.ABI
+	if len(self.ABI) > 0 {
+		inline, err := parseABI(self.ABI)
+		if err != nil {
+			return abi.ABI{}, err
+		}
+		parts = append(parts, inline)
+	}
+	if self.File != "" {
+		file := self.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		fromFile, err := loadABI(file)
+		if err != nil {
+			return abi.ABI{}, err
+		}
+		parts = append(parts, fromFile)
+	}
+	for _, sig := range self.Signatures {
+		fromSig, err := parseSignatureABI(sig)
+		if err != nil {
+			return abi.ABI{}, err
+		}
+		parts = append(parts, fromSig)
+	}
+	for _, part := range parts {
+		for name, method := range part.Methods {
+			parsed.Methods[name] = method
+		}
+		for name, event := range part.Events {
+			parsed.Events[name] = event
+		}
+		for name, e := range part.Errors {
+			parsed.Errors[name] = e
+		}
+		if part.Constructor.Type == abi.Constructor {
+			parsed.Constructor = part.Constructor
+		}
+	}
+	return parsed, nil
+}
+
+type ContractAbiCmd struct {
+	Add    ContractAbiAddCmd    `cmd:"" help:"attach an ABI to an env contract"`
+	Show   ContractAbiShowCmd   `cmd:"" help:"show the ABI of an env contract"`
+	Remove ContractAbiRemoveCmd `cmd:"" help:"remove the ABI of an env contract"`
+}
+
+type ContractAbiAddCmd struct {
+	Address string   `arg:"" help:"contract address, added to the env contracts when missing"`
+	ABI     string   `arg:"" name:"abi" help:"JSON ABI file or a function signature like 'balanceOf(address) returns (uint256)'"`
+	Ref     bool     `optional:"" help:"store only the path to the ABI file relative to the env file instead of its content"`
+	Tags    []string `optional:"" name:"tag" help:"tags of the contract when it isn't in the env"`
+}
+
+func (self *ContractAbiAddCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	addr, err := parseAddress(self.Address)
+	if err != nil {
+		return err
+	}
+
+	// Validate before saving.
+	if _, err := loadABI(self.ABI); err != nil {
+		return err
+	}
+
+	filePath, e, ext, err := loadEnvWithExt(cli)
+	if err != nil {
+		return err
+	}
+
+	if !envContains(e.Contracts, addr) {
+		e.Contracts = append(e.Contracts, env.Contract{Address: addr, Tags: self.Tags})
+		level.Info(logger).Log("msg", "contract added to the env", "address", addr)
+	}
+
+	if ext.ContractABIs == nil {
+		ext.ContractABIs = make(map[common.Address]ContractABI)
+	}
+	contractABI := ext.ContractABIs[addr]
+	switch {
+	case strings.Contains(self.ABI, "("):
+		contractABI.Signatures = append(contractABI.Signatures, self.ABI)
+	case self.Ref:
+		file, err := envRelPath(filePath, self.ABI)
+		if err != nil {
+			return err
+		}
+		contractABI.File = file
+	default:
+		content, err := os.ReadFile(self.ABI)
+		if err != nil {
+			return errors.Wrap(err, "read ABI file")
+		}
+		contractABI.ABI = abiJSON(content)
+	}
+	ext.ContractABIs[addr] = contractABI
+
+	err = saveEnv(filePath, e, &ext)
+	if err != nil {
+		return err
+	}
+	level.Info(logger).Log("msg", "contract ABI added", "address", addr)
+	return nil
+}
+
+type ContractAbiShowCmd struct {
+	Address string `arg:"" help:"contract address"`
+}
+
+func (self *ContractAbiShowCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	addr, err := parseAddress(self.Address)
+	if err != nil {
+		return err
+	}
+	abis, err := cli.ABIs()
+	if err != nil {
+		return err
+	}
+	contractABI, ok := abis[addr]
+	if !ok {
+		return errors.Errorf("no ABI for contract:%v", addr)
+	}
+
+	result := ContractAbiResult{Address: addr}
+	for _, method := range contractABI.Methods {
+		result.Methods = append(result.Methods, method.String())
+	}
+	for _, event := range contractABI.Events {
+		result.Events = append(result.Events, event.String())
+	}
+	for _, e := range contractABI.Errors {
+		result.Errors = append(result.Errors, e.String())
+	}
+	sort.Strings(result.Methods)
+	sort.Strings(result.Events)
+	sort.Strings(result.Errors)
+	return cli.Print(result)
+}
+
+type ContractAbiRemoveCmd struct {
+	Address string `arg:"" help:"contract address"`
+}
+
+func (self *ContractAbiRemoveCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	addr, err := parseAddress(self.Address)
+	if err != nil {
+		return err
+	}
+	filePath, e, ext, err := loadEnvWithExt(cli)
+	if err != nil {
+		return err
+	}
+	if _, ok := ext.ContractABIs[addr]; !ok {
+		return errors.Errorf("no ABI for contract:%v", addr)
+	}
+	delete(ext.ContractABIs, addr)
+
+	err = saveEnv(filePath, e, &ext)
+	if err != nil {
+		return err
+	}
+	level.Info(logger).Log("msg", "contract ABI removed", "address", addr)
+	return nil
+}
+
+// loadEnvWithExt loads the full env and the wallger specific objects for modifications.
+func loadEnvWithExt(cli *CLI) (string, env.Env, EnvExt, error) {
+	filePath, err := cli.FilePath()
+	if err != nil {
+		return "", env.Env{}, EnvExt{}, err
+	}
+	e, err := env.LoadFromFile(filePath)
+	if err != nil {
+		return "", env.Env{}, EnvExt{}, errors.Wrap(err, "loading env from file")
+	}
+	ext, err := loadEnvExt(filePath)
+	if err != nil {
+		return "", env.Env{}, EnvExt{}, err
+	}
+	return filePath, e, ext, nil
+}
+
+// envRelPath returns the path relative to the env file directory
+// so that the env and the referenced files can be moved together.
+func envRelPath(envFile string, path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", errors.Wrap(err, "absolute path")
+	}
+	envDir, err := filepath.Abs(filepath.Dir(envFile))
+	if err != nil {
+		return "", errors.Wrap(err, "absolute env dir")
+	}
+	rel, err := filepath.Rel(envDir, abs)
+	if err != nil {
+		// Paths on different volumes can't be relative.
+		return abs, nil
+	}
+	return rel, nil
+}
+
+func envContains(contracts []env.Contract, addr common.Address) bool {
+	for _, contract := range contracts {
+		if contract.Address == addr {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestContractABIParse(t *testing.T) {
+	dir := t.TempDir()
+	const fileABI = `[{"type":"function","name":"balanceOf","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]}]`
+	if err := os.MkdirAll(filepath.Join(dir, "abis"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "abis", "token.json"), []byte(fileABI), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		abi     ContractABI
+		methods []string
+		err     bool
+	}{
+		{name: "relative file", abi: ContractABI{File: filepath.Join("abis", "token.json")}, methods: []string{"balanceOf"}},
+		{name: "absolute file", abi: ContractABI{File: filepath.Join(dir, "abis", "token.json")}, methods: []string{"balanceOf"}},
+		{
+			name: "merged",
+			abi: ContractABI{
+				ABI:        []byte(`{"abi":[{"type":"function","name":"name","inputs":[],"outputs":[{"name":"","type":"string"}]}]}`),
+				File:       filepath.Join("abis", "token.json"),
+				Signatures: []string{"transfer(address,uint256) returns (bool)"},
+			},
+			methods: []string{"balanceOf", "name", "transfer"},
+		},
+		{name: "missing file", abi: ContractABI{File: "missing.json"}, err: true},
+		{name: "invalid signature", abi: ContractABI{Signatures: []string{"transfer"}}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := tt.abi.Parse(dir)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var methods []string
+			for name := range parsed.Methods {
+				methods = append(methods, name)
+			}
+			sort.Strings(methods)
+			if len(methods) != len(tt.methods) {
+				t.Fatalf("methods got:%v, want:%v", methods, tt.methods)
+			}
+			for i := range methods {
+				if methods[i] != tt.methods[i] {
+					t.Fatalf("methods got:%v, want:%v", methods, tt.methods)
+				}
+			}
+		})
+	}
+}
+
+func TestEnvRelPath(t *testing.T) {
+	dir := t.TempDir()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		envFile string
+		path    string
+		want    string
+	}{
+		{name: "next to the env", envFile: filepath.Join(dir, "env.json"), path: filepath.Join(dir, "abis", "token.json"), want: filepath.Join("abis", "token.json")},
+		{name: "outside the env dir", envFile: filepath.Join(dir, "envs", "env.json"), path: filepath.Join(dir, "abis", "token.json"), want: filepath.Join("..", "abis", "token.json")},
+		// Relative paths are relative to the working directory and not to the env file.
+		{name: "relative path", envFile: filepath.Join(cwd, "envs", "env.json"), path: filepath.Join("abis", "token.json"), want: filepath.Join("..", "abis", "token.json")},
+		{name: "relative env file", envFile: "env.json", path: filepath.Join("abis", "token.json"), want: filepath.Join("abis", "token.json")},
+	}
+	for _, tt := range tests {
+		got, err := envRelPath(tt.envFile, tt.path)
+		if err != nil {
+			t.Errorf("%v error:%v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%v got:%v, want:%v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+)
+
+// knownErrorsABI are the custom errors of the common OpenZeppelin contracts.
+const knownErrorsABI = `[
+	{"type":"error","name":"ERC20InsufficientBalance","inputs":[{"name":"sender","type":"address"},{"name":"balance","type":"uint256"},{"name":"needed","type":"uint256"}]},
+	{"type":"error","name":"ERC20InsufficientAllowance","inputs":[{"name":"spender","type":"address"},{"name":"allowance","type":"uint256"},{"name":"needed","type":"uint256"}]},
+	{"type":"error","name":"ERC20InvalidSender","inputs":[{"name":"sender","type":"address"}]},
+	{"type":"error","name":"ERC20InvalidReceiver","inputs":[{"name":"receiver","type":"address"}]},
+	{"type":"error","name":"ERC20InvalidApprover","inputs":[{"name":"approver","type":"address"}]},
+	{"type":"error","name":"ERC20InvalidSpender","inputs":[{"name":"spender","type":"address"}]},
+	{"type":"error","name":"OwnableUnauthorizedAccount","inputs":[{"name":"account","type":"address"}]},
+	{"type":"error","name":"OwnableInvalidOwner","inputs":[{"name":"owner","type":"address"}]}
+]`
+
+var (
+	errorSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+	panicSelector = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+)
+
+// panicReasons are the solidity panic codes.
+var panicReasons = map[uint64]string{
+	0x00: "generic compiler panic",
+	0x01: "assert failed",
+	0x11: "arithmetic overflow or underflow",
+	0x12: "division or modulo by zero",
+	0x21: "invalid enum value",
+	0x22: "invalid storage byte array encoding",
+	0x31: "pop on an empty array",
+	0x32: "array index out of bounds",
+	0x41: "out of memory",
+	0x51: "call to a zero initialized function",
+}
+
+// simulateTx runs the TX with eth_call at the pending block and
+// returns the decoded revert reason when the TX reverts.
+// The custom errors are decoded with the given ABIs and the known errors.
+func simulateTx(ctx context.Context, client bind.ContractCaller, msg ethereum.CallMsg, abis ...abi.ABI) (string, bool, error) {
+	_, err := callPending(ctx, client, msg)
+	if err == nil {
+		return "", false, nil
+	}
+
+	if revert, ok := revertData(err); ok {
+		return decodeRevert(revert, abis...), true, nil
+	}
+	if isRevert(err) {
+		return "no reason", true, nil
+	}
+	return "", false, err
+}
+
+// revertData returns the revert data of a call error.
+func revertData(err error) ([]byte, bool) {
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if data, ok := dataErr.ErrorData().(string); ok {
+			if revert, decodeErr := hexutil.Decode(data); decodeErr == nil {
+				return revert, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// isRevert returns true when the call error is an execution revert and not an RPC failure.
+func isRevert(err error) bool {
+	if _, ok := revertData(err); ok {
+		return true
+	}
+	// Reverts without a reason don't have any data.
+	return strings.Contains(err.Error(), "execution reverted")
+}
+
+// callPending runs the call at the pending block when the client supports it.
+func callPending(ctx context.Context, client bind.ContractCaller, msg ethereum.CallMsg) ([]byte, error) {
+	if pendingClient, ok := client.(bind.PendingContractCaller); ok {
+		return pendingClient.PendingCallContract(ctx, msg)
+	}
+	return client.CallContract(ctx, msg, nil)
+}
+
+// decodeRevert returns a readable reason of the revert data encoded as
+// Error(string), Panic(uint256) or a custom error of the given ABIs or the known errors.
+func decodeRevert(data []byte, abis ...abi.ABI) string {
+	if len(data) < 4 {
+		return "no reason"
+	}
+	switch {
+	case bytes.Equal(data[:4], errorSelector):
+		reason, err := abi.UnpackRevert(data)
+		if err == nil {
+			return reason
+		}
+	case bytes.Equal(data[:4], panicSelector):
+		if len(data) == 36 {
+			code := new(big.Int).SetBytes(data[4:])
+			reason, ok := panicReasons[code.Uint64()]
+			if !ok || !code.IsUint64() {
+				reason = "unknown panic code"
+			}
+			return fmt.Sprintf("panic 0x%x: %v", code, reason)
+		}
+	default:
+		if knownErrors, err := abi.JSON(strings.NewReader(knownErrorsABI)); err == nil {
+			abis = append(abis, knownErrors)
+		}
+		for _, contractABI := range abis {
+			for _, e := range contractABI.Errors {
+				if !bytes.Equal(data[:4], e.ID[:4]) {
+					continue
+				}
+				values, err := e.Inputs.Unpack(data[4:])
+				if err != nil {
+					continue
+				}
+				args := make([]string, len(values))
+				for i, v := range values {
+					args[i] = formatValue(v)
+				}
+				return e.Name + "(" + strings.Join(args, ", ") + ")"
+			}
+		}
+	}
+	return "unknown revert data " + hexutil.Encode(data)
+}
@@ -0,0 +1,100 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+)
+
+type dataError struct {
+	data interface{}
+}
+
+func (self dataError) Error() string          { return "execution reverted" }
+func (self dataError) ErrorData() interface{} { return self.data }
+
+func TestDecodeRevert(t *testing.T) {
+	customABI, err := abi.JSON(strings.NewReader(`[{"type":"error","name":"Unauthorized","inputs":[{"name":"caller","type":"address"}]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	known, err := abi.JSON(strings.NewReader(knownErrorsABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	encode := func(selector []byte, args abi.Arguments, values ...interface{}) []byte {
+		packed, err := args.Pack(values...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return append(append([]byte{}, selector...), packed...)
+	}
+	stringType, _ := abi.NewType("string", "", nil)
+	uintType, _ := abi.NewType("uint256", "", nil)
+	balanceErr := known.Errors["ERC20InsufficientBalance"]
+	unauthorized := customABI.Errors["Unauthorized"]
+	unauthorizedData := encode(unauthorized.ID[:4], unauthorized.Inputs, addr)
+
+	tests := []struct {
+		name string
+		data []byte
+		abis []abi.ABI
+		want string
+	}{
+		{name: "empty", want: "no reason"},
+		{name: "short", data: []byte{1, 2}, want: "no reason"},
+		{name: "error string", data: encode(errorSelector, abi.Arguments{{Type: stringType}}, "not enough balance"), want: "not enough balance"},
+		{name: "panic", data: encode(panicSelector, abi.Arguments{{Type: uintType}}, big.NewInt(0x11)), want: "panic 0x11: arithmetic overflow or underflow"},
+		{name: "unknown panic", data: encode(panicSelector, abi.Arguments{{Type: uintType}}, big.NewInt(0x99)), want: "panic 0x99: unknown panic code"},
+		{
+			name: "known error",
+			data: encode(balanceErr.ID[:4], balanceErr.Inputs, addr, big.NewInt(1), big.NewInt(2)),
+			want: "ERC20InsufficientBalance(" + addr.Hex() + ", 1, 2)",
+		},
+		{
+			name: "custom error",
+			data: unauthorizedData,
+			abis: []abi.ABI{customABI},
+			want: "Unauthorized(" + addr.Hex() + ")",
+		},
+		{
+			name: "custom error without the ABI",
+			data: unauthorizedData,
+			want: "unknown revert data " + hexutil.Encode(unauthorizedData),
+		},
+		{name: "unknown selector", data: []byte{0x12, 0x34, 0x56, 0x78}, want: "unknown revert data 0x12345678"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeRevert(tt.data, tt.abis...); got != tt.want {
+				t.Fatalf("got:%v, want:%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRevert(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "revert data", err: errors.Wrap(dataError{data: "0x08c379a0"}, "call"), want: true},
+		{name: "no reason", err: errors.New("execution reverted"), want: true},
+		{name: "RPC failure", err: errors.New("connection refused")},
+	}
+	for _, tt := range tests {
+		if got := isRevert(tt.err); got != tt.want {
+			t.Errorf("%v got:%v, want:%v", tt.name, got, tt.want)
+		}
+	}
+}
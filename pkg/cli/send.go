@@ -0,0 +1,160 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/cryptoriums/packages/env"
+	tx_p "github.com/cryptoriums/packages/tx"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+)
+
+type txBackend interface {
+	receiptBackend
+	NetworkID() int64
+}
+
+// sendTx builds the TX, asks for a confirmation with the max TX fee
+// and then signs, broadcasts and prints the TX.
+// When the TX is built for offline signing it is written to a file instead.
+func (self *Gas) sendTx(ctx context.Context, cli *CLI, client txBackend, from env.Account, nonce uint64, fees Fees, to common.Address, value *big.Int, data []byte, action string) error {
+	unsigned, err := self.buildTx(ctx, cli, client, from.Pub, nonce, fees, to, value, data, action)
+	if err != nil {
+		return err
+	}
+	if self.unsignedOut != "" {
+		return writeUnsignedTx(cli, self.unsignedOut, unsigned)
+	}
+
+	err = cli.Confirm("Confirm " + unsigned.String())
+	if err != nil {
+		return err
+	}
+
+	tx, err := signAndSend(ctx, client, from, unsigned)
+	if err != nil {
+		return err
+	}
+
+	err = cli.Print(TxResult{Hash: tx.Hash(), Nonce: nonce, From: from.Pub})
+	if err != nil {
+		return err
+	}
+	_, err = self.WaitReceipt(ctx, cli, client, tx, from.Pub)
+	return err
+}
+
+// buildTx simulates the TX and estimates its gas limit.
+// TXs that revert in the simulation are built only when forced.
+func (self *Gas) buildTx(ctx context.Context, cli *CLI, client txBackend, from common.Address, nonce uint64, fees Fees, to common.Address, value *big.Int, data []byte, action string) (UnsignedTx, error) {
+	msg := txMsg(from, &to, value, data, fees)
+
+	known, err := cli.KnownABIs(to)
+	if err != nil {
+		return UnsignedTx{}, err
+	}
+	reason, reverted, err := simulateTx(ctx, client, msg, append(self.abis, known...)...)
+	if err != nil {
+		return UnsignedTx{}, errors.Wrap(err, "simulateTx")
+	}
+	if reverted {
+		if !self.Force {
+			return UnsignedTx{}, errors.Errorf("TX reverts in the simulation:%v, use --force to send it anyway", reason)
+		}
+		// The node can't estimate the gas of a reverting TX.
+		if self.GasLimit == nil {
+			return UnsignedTx{}, errors.Errorf("TX reverts in the simulation:%v, forcing it requires --gas-limit", reason)
+		}
+		fmt.Fprintln(os.Stderr, "TX reverts in the simulation:", reason)
+	}
+
+	gasLimit, err := self.Limit(ctx, client, msg)
+	if err != nil {
+		return UnsignedTx{}, err
+	}
+
+	return UnsignedTx{
+		ChainID:     client.NetworkID(),
+		From:        from,
+		To:          to,
+		Nonce:       nonce,
+		Value:       msg.Value,
+		Data:        data,
+		Gas:         gasLimit,
+		MaxFee:      fees.MaxFee,
+		Tip:         fees.Tip,
+		GasPrice:    fees.GasPrice,
+		Description: action,
+	}, nil
+}
+
+// signAndSend signs the TX with the decrypted key of the account and broadcasts it.
+func signAndSend(ctx context.Context, client txBackend, from env.Account, unsigned UnsignedTx) (*types.Transaction, error) {
+	ethAcc, err := tx_p.AccountFromPrvKey(from.Priv)
+	if err != nil {
+		return nil, errors.Wrap(err, "AccountFromPrvKey")
+	}
+	tx, err := unsigned.Sign(ethAcc.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	err = client.SendTransaction(ctx, tx)
+	if err != nil {
+		return nil, errors.Wrap(err, "SendTransaction")
+	}
+	return tx, nil
+}
+
+// selectSender returns the sender account with its key decrypted
+// unless the TX is built for offline signing where the key isn't needed.
+func (self *Gas) selectSender(accs []env.Account, addr string, firstRun bool, msg string) (env.Account, string, error) {
+	if self.unsignedOut != "" {
+		acc, err := selectAccount(accs, addr, firstRun, msg)
+		return acc, "", err
+	}
+	return selectAccountAndDecrypt(accs, addr, firstRun, msg)
+}
+
+// txMsg returns the call message of a TX used for the gas estimation.
+func txMsg(from common.Address, to *common.Address, value *big.Int, data []byte, fees Fees) ethereum.CallMsg {
+	if value == nil {
+		value = big.NewInt(0)
+	}
+	return ethereum.CallMsg{
+		From:      from,
+		To:        to,
+		Value:     value,
+		Data:      data,
+		GasPrice:  fees.GasPrice,
+		GasFeeCap: fees.MaxFee,
+		GasTipCap: fees.Tip,
+	}
+}
+
+// packCall returns the input data of a contract binding call.
+// All values that the binding would otherwise request from the node are set
+// and the TX isn't signed nor sent so it doesn't make any requests.
+func packCall(call func(opts *bind.TransactOpts) (*types.Transaction, error)) ([]byte, error) {
+	tx, err := call(&bind.TransactOpts{
+		Nonce:    big.NewInt(0),
+		GasPrice: big.NewInt(0),
+		GasLimit: 1,
+		Signer: func(_ common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			return tx, nil
+		},
+		NoSend: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tx.Data(), nil
+}
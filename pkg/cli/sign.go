@@ -0,0 +1,265 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cryptoriums/packages/env"
+	"github.com/cryptoriums/packages/prompt"
+	tx_p "github.com/cryptoriums/packages/tx"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+)
+
+type SignCmd struct {
+	Message   SignMessageCmd   `cmd:"" help:"sign a message with EIP-191 personal_sign"`
+	TypedData SignTypedDataCmd `cmd:"" help:"sign EIP-712 typed data"`
+}
+
+// MessageFlags select the message of a personal_sign signature.
+type MessageFlags struct {
+	Message string `arg:"" optional:"" help:"message to sign, prompted for when not set"`
+	File    string `optional:"" type:"existingfile" help:"file with the message"`
+	Hex     bool   `optional:"" help:"the message is hex encoded bytes"`
+}
+
+// Hash returns the EIP-191 hash of the message.
+func (self *MessageFlags) Hash() ([]byte, error) {
+	msg := self.Message
+	switch {
+	case self.File != "":
+		content, err := os.ReadFile(self.File)
+		if err != nil {
+			return nil, errors.Wrap(err, "read message file")
+		}
+		msg = string(content)
+	case msg == "":
+		var err error
+		msg, err = prompt.PromptInput("Enter message: ")
+		if err != nil {
+			return nil, errors.Wrap(err, "message prompt")
+		}
+	}
+
+	data := []byte(msg)
+	if self.Hex {
+		var err error
+		data, err = hexutil.Decode(strings.TrimSpace(msg))
+		if err != nil {
+			return nil, errors.Wrap(err, "decode hex message")
+		}
+	}
+	return accounts.TextHash(data), nil
+}
+
+type SignMessageCmd struct {
+	From string `optional:"" env:"WALLGER_FROM" help:"signer address, prompted for when not set"`
+	MessageFlags
+}
+
+func (self *SignMessageCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	hash, err := self.MessageFlags.Hash()
+	if err != nil {
+		return err
+	}
+	e, err := cli.Load("enter tags separated by a comma: ")
+	if err != nil {
+		return err
+	}
+	return signHash(cli, e, self.From, hash)
+}
+
+type SignTypedDataCmd struct {
+	From string `optional:"" env:"WALLGER_FROM" help:"signer address, prompted for when not set"`
+	File string `arg:"" type:"existingfile" help:"JSON file with the EIP-712 types, primaryType, domain and message"`
+}
+
+func (self *SignTypedDataCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	typedData, err := loadTypedData(self.File)
+	if err != nil {
+		return err
+	}
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return errors.Wrap(err, "hash typed data")
+	}
+
+	formatted, err := typedData.Format()
+	if err != nil {
+		return errors.Wrap(err, "format typed data")
+	}
+	var breakdown strings.Builder
+	for _, nvt := range formatted {
+		formatTypedValue(&breakdown, nvt, 0)
+	}
+	err = cli.Confirm(breakdown.String() + "Sign the typed data?")
+	if err != nil {
+		return err
+	}
+
+	e, err := cli.Load("enter tags separated by a comma: ")
+	if err != nil {
+		return err
+	}
+	return signHash(cli, e, self.From, hash)
+}
+
+func signHash(cli *CLI, e env.Env, from string, hash []byte) error {
+	acc, _, err := selectAccountAndDecrypt(e.Accounts, from, false, "Select signer's pub address:")
+	if err != nil {
+		return errors.Wrap(err, "select signer")
+	}
+	signer, sig, err := signDigest(acc, hash)
+	if err != nil {
+		return err
+	}
+	return cli.Print(SignatureResult{Signer: signer, Hash: common.BytesToHash(hash), Signature: sig})
+}
+
+// signDigest signs the hash with the decrypted account key.
+// The recovery ID is 27 or 28 as wallets and contracts expect it.
+func signDigest(acc env.Account, hash []byte) (common.Address, []byte, error) {
+	ethAcc, err := tx_p.AccountFromPrvKey(acc.Priv)
+	if err != nil {
+		return common.Address{}, nil, errors.Wrap(err, "AccountFromPrvKey")
+	}
+	sig, err := crypto.Sign(hash, ethAcc.PrivateKey)
+	if err != nil {
+		return common.Address{}, nil, errors.Wrap(err, "sign")
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return ethAcc.PublicKey, sig, nil
+}
+
+type VerifyCmd struct {
+	Signature string `required:"" help:"hex encoded signature"`
+	TypedData string `optional:"" type:"existingfile" help:"JSON file with the EIP-712 typed data that was signed instead of a message"`
+	Address   string `optional:"" help:"expected signer address, the command fails when the signer is another one"`
+	MessageFlags
+}
+
+func (self *VerifyCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	sig, err := hexutil.Decode(strings.TrimSpace(self.Signature))
+	if err != nil {
+		return errors.Wrap(err, "decode signature")
+	}
+	if len(sig) != crypto.SignatureLength {
+		return errors.Errorf("signature should be %v bytes, got:%v", crypto.SignatureLength, len(sig))
+	}
+
+	var hash []byte
+	if self.TypedData != "" {
+		typedData, err := loadTypedData(self.TypedData)
+		if err != nil {
+			return err
+		}
+		hash, _, err = apitypes.TypedDataAndHash(typedData)
+		if err != nil {
+			return errors.Wrap(err, "hash typed data")
+		}
+	} else {
+		hash, err = self.MessageFlags.Hash()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Don't modify the decoded signature which is used in the output.
+	rawSig := append([]byte{}, sig...)
+	if rawSig[crypto.RecoveryIDOffset] >= 27 {
+		rawSig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(hash, rawSig)
+	if err != nil {
+		return errors.Wrap(err, "recover signer")
+	}
+	signer := crypto.PubkeyToAddress(*pub)
+
+	e, err := cli.Load("enter tags separated by a comma: ")
+	if err != nil {
+		return err
+	}
+	result := VerifyResult{Signer: signer, Hash: common.BytesToHash(hash)}
+	for _, acc := range e.Accounts {
+		if acc.Pub == signer {
+			result.EnvAccount, result.Tags = true, acc.Tags
+			break
+		}
+	}
+	err = cli.Print(result)
+	if err != nil {
+		return err
+	}
+
+	if self.Address != "" {
+		expected, err := parseAddress(self.Address)
+		if err != nil {
+			return errors.Wrap(err, "expected signer")
+		}
+		if expected != signer {
+			return errors.Errorf("signer:%v isn't the expected one:%v", signer, expected)
+		}
+	}
+	return nil
+}
+
+func loadTypedData(file string) (apitypes.TypedData, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return apitypes.TypedData{}, errors.Wrap(err, "read typed data file")
+	}
+	// Wallets send the chain ID as a number but the go-ethereum type accepts only strings.
+	var payload struct {
+		Domain map[string]json.RawMessage `json:"domain"`
+	}
+	if err := json.Unmarshal(content, &payload); err != nil {
+		return apitypes.TypedData{}, errors.Wrap(err, "unmarshal typed data")
+	}
+	if chainID, ok := payload.Domain["chainId"]; ok && !strings.HasPrefix(string(chainID), `"`) {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(content, &fields); err != nil {
+			return apitypes.TypedData{}, errors.Wrap(err, "unmarshal typed data")
+		}
+		payload.Domain["chainId"] = json.RawMessage(`"` + string(chainID) + `"`)
+		fields["domain"], err = json.Marshal(payload.Domain)
+		if err != nil {
+			return apitypes.TypedData{}, errors.Wrap(err, "marshal typed data domain")
+		}
+		content, err = json.Marshal(fields)
+		if err != nil {
+			return apitypes.TypedData{}, errors.Wrap(err, "marshal typed data")
+		}
+	}
+
+	var typedData apitypes.TypedData
+	if err := json.Unmarshal(content, &typedData); err != nil {
+		return apitypes.TypedData{}, errors.Wrap(err, "unmarshal typed data")
+	}
+	return typedData, nil
+}
+
+// formatTypedValue writes the typed data fields one per line
+// with the nested structs indented.
+func formatTypedValue(w *strings.Builder, nvt *apitypes.NameValueType, depth int) {
+	indent := strings.Repeat("  ", depth)
+	nested, ok := nvt.Value.([]*apitypes.NameValueType)
+	if !ok {
+		fmt.Fprintf(w, "%v%v (%v): %v\n", indent, nvt.Name, nvt.Typ, nvt.Value)
+		return
+	}
+	fmt.Fprintf(w, "%v%v (%v):\n", indent, nvt.Name, nvt.Typ)
+	for _, v := range nested {
+		formatTypedValue(w, v, depth+1)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	client_p "github.com/cryptoriums/packages/client"
+	"github.com/cryptoriums/packages/contracts/bindings/interfaces"
+	"github.com/cryptoriums/packages/env"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+type AccountSweepCmd struct {
+	To     string   `optional:"" env:"WALLGER_TO" help:"destination account or contract address, prompted for when not set"`
+	Tokens []string `optional:"" name:"token" default:"ETH" help:"ETH or token contract addresses to sweep, ETH is swept last after the tokens"`
+	Dust   string   `default:"0" help:"ETH amount below which the ETH left after the TX fee isn't swept"`
+	Gas
+}
+
+// Run moves the full balances of all accounts matching the tags to the destination.
+// The ETH amount is the balance minus the max fees of all sweep TXs of the account
+// so some ETH remains when the TXs pay less than the max fee.
+func (self *AccountSweepCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	e, err := cli.Load("enter tags for accounts to be swept separated by a comma: ")
+	if err != nil {
+		return err
+	}
+
+	client, err := client_p.NewClientCachedNetID(ctx, logger, e.Nodes[0].URL)
+	if err != nil {
+		return errors.Wrap(err, "NewClientCachedNetID")
+	}
+
+	dest, err := selectAccount(accountsAndContracts(e), self.To, false, "Select destination pub address:")
+	if err != nil {
+		return errors.Wrap(err, "select destination")
+	}
+
+	dust, err := ParseAmount(self.Dust, ethDecimals)
+	if err != nil {
+		return errors.Wrap(err, "dust")
+	}
+
+	var tokens []Token
+	var sweepETH bool
+	for _, input := range self.Tokens {
+		token, err := selectToken(ctx, cli, client, client.NetworkID(), input)
+		if err != nil {
+			return errors.Wrapf(err, "selectToken:%v", input)
+		}
+		if token.IsETH() {
+			sweepETH = true
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+
+	fees, err := self.Fees(ctx, client, cli.Yes)
+	if err != nil {
+		return err
+	}
+
+	var txs []batchTx
+	var result SweepResult
+accounts:
+	for _, acc := range e.Accounts {
+		if acc.Pub == dest.Pub {
+			continue
+		}
+
+		nonce, err := client.PendingNonceAt(ctx, acc.Pub)
+		if err != nil {
+			return errors.Wrap(err, "PendingNonceAt")
+		}
+
+		// The max fees of the token TXs are reserved from the ETH balance.
+		reserved := big.NewInt(0)
+		var accTxs []batchTx
+		var accSweeps []Sweep
+		for _, token := range tokens {
+			balance, err := tokenBalance(ctx, client, token, acc.Pub)
+			if err != nil {
+				return errors.Wrapf(err, "balance of:%v token:%v", acc.Pub, token.Name)
+			}
+			if balance.Sign() == 0 {
+				continue
+			}
+			erc20I, err := interfaces.NewIERC20(token.Address, client)
+			if err != nil {
+				return errors.Wrap(err, "NewIERC20")
+			}
+			data, err := packCall(func(opts *bind.TransactOpts) (*types.Transaction, error) {
+				return erc20I.Transfer(opts, dest.Pub, balance)
+			})
+			if err != nil {
+				return errors.Wrap(err, "pack Transfer")
+			}
+
+			action := fmt.Sprintf("sweep of:%v from:%v, to:%v, amount:%v", token.Name, acc.Pub, dest.Pub, FormatAmount(balance, token.Decimals))
+			unsigned, err := self.buildTx(ctx, cli, client, acc.Pub, nonce, fees, token.Address, nil, data, action)
+			if err != nil {
+				// Usually the account has no ETH for the gas so the other accounts are still swept.
+				level.Warn(logger).Log("msg", "skipping the account sweep", "from", acc.Pub, "token", token.Name, "err", err)
+				result.Skipped = append(result.Skipped, SkippedSweep{From: acc.Pub, Token: token.Name, Reason: err.Error()})
+				continue accounts
+			}
+			nonce++
+			reserved.Add(reserved, maxTxFee(unsigned))
+			accTxs = append(accTxs, batchTx{from: acc, token: token, amount: balance, unsigned: unsigned})
+			accSweeps = append(accSweeps, Sweep{From: acc.Pub, Token: token.Name, Amount: FormatAmount(balance, token.Decimals)})
+		}
+		txs = append(txs, accTxs...)
+		result.Sweeps = append(result.Sweeps, accSweeps...)
+
+		if !sweepETH {
+			continue
+		}
+		eth := Token{Name: env.ETH_TOKEN.Name, Decimals: ethDecimals}
+		balance, err := tokenBalance(ctx, client, eth, acc.Pub)
+		if err != nil {
+			return errors.Wrapf(err, "ETH balance of:%v", acc.Pub)
+		}
+		// Contract destinations need more gas than a plain transfer.
+		gasLimit, err := self.Limit(ctx, client, txMsg(acc.Pub, &dest.Pub, big.NewInt(1), nil, fees))
+		if err != nil {
+			level.Warn(logger).Log("msg", "skipping the account ETH sweep", "from", acc.Pub, "err", err)
+			result.Skipped = append(result.Skipped, SkippedSweep{From: acc.Pub, Token: eth.Name, Reason: err.Error()})
+			continue
+		}
+		amount := new(big.Int).Sub(balance, reserved)
+		amount.Sub(amount, maxTxFee(UnsignedTx{Gas: gasLimit, MaxFee: fees.MaxFee, Tip: fees.Tip, GasPrice: fees.GasPrice}))
+		if amount.Sign() <= 0 || amount.Cmp(dust) < 0 {
+			continue
+		}
+
+		// The amount is computed with this gas limit so it isn't estimated again.
+		ethGas := self.Gas
+		ethGas.GasLimit = &gasLimit
+		action := fmt.Sprintf("sweep of:ETH from:%v, to:%v, amount:%v", acc.Pub, dest.Pub, FormatAmount(amount, ethDecimals))
+		unsigned, err := ethGas.buildTx(ctx, cli, client, acc.Pub, nonce, fees, dest.Pub, amount, nil, action)
+		if err != nil {
+			level.Warn(logger).Log("msg", "skipping the account ETH sweep", "from", acc.Pub, "err", err)
+			result.Skipped = append(result.Skipped, SkippedSweep{From: acc.Pub, Token: eth.Name, Reason: err.Error()})
+			continue
+		}
+		txs = append(txs, batchTx{from: acc, token: eth, amount: amount, unsigned: unsigned})
+		result.Sweeps = append(result.Sweeps, Sweep{From: acc.Pub, Token: eth.Name, Amount: FormatAmount(amount, ethDecimals)})
+	}
+
+	err = cli.Print(result)
+	if err != nil {
+		return err
+	}
+	if len(txs) == 0 {
+		return errors.New("nothing to sweep")
+	}
+	err = cli.Confirm(fmt.Sprintf("Confirm %v sweeps to:%v, %v", len(txs), dest.Pub, fees))
+	if err != nil {
+		return err
+	}
+
+	return self.sendBatch(ctx, cli, logger, client, e.Accounts, txs)
+}
+
+// maxTxFee returns the fee that the TX pays when it uses all its gas at the max fee.
+func maxTxFee(tx UnsignedTx) *big.Int {
+	return new(big.Int).Mul(new(big.Int).SetUint64(tx.Gas), tx.Fees().Cap())
+}
@@ -6,46 +6,146 @@ package cli
 import (
 	"context"
 	"fmt"
-	"strconv"
+	"math/big"
+	"os"
 	"strings"
 
-	big_p "github.com/cryptoriums/packages/big"
 	client_p "github.com/cryptoriums/packages/client"
 	"github.com/cryptoriums/packages/contracts/bindings/interfaces"
 	"github.com/cryptoriums/packages/env"
 	"github.com/cryptoriums/packages/prompt"
-	tx_p "github.com/cryptoriums/packages/tx"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/params"
 	"github.com/go-kit/log"
-	"github.com/go-kit/log/level"
-	"github.com/jinzhu/copier"
 	"github.com/pkg/errors"
 )
 
 type TokenCmd struct {
-	Transfer TokenTransferCmd `cmd:"" help:"transfer eth of other tokens"`
-	Approve  TokenApproveCmd  `cmd:"" help:"approve tokens spendings"`
+	Transfer      TokenTransferCmd      `cmd:"" help:"transfer eth of other tokens"`
+	BatchTransfer TokenBatchTransferCmd `cmd:"" help:"transfer eth or tokens to many receivers from a CSV or JSON file"`
+	Approve       TokenApproveCmd       `cmd:"" help:"approve tokens spendings"`
+	Permit        TokenPermitCmd        `cmd:"" help:"sign an EIP-2612 or Permit2 approval and optionally submit it from another account"`
+	Allowances    TokenAllowancesCmd    `cmd:"" help:"list the outstanding allowances of the env accounts and revoke them"`
+	Add           TokenAddCmd           `cmd:"" help:"add a custom token to the env file"`
+	List          TokenListCmd          `cmd:"" help:"list the custom tokens of the env file"`
+	Remove        TokenRemoveCmd        `cmd:"" help:"remove a custom token from the env file"`
+	Import        TokenImportCmd        `cmd:"" help:"import the custom tokens of a token list file"`
 }
 
-type TokenApproveCmd struct{}
+// Token is a token selected for a command.
+// The ETH token has an empty address.
+type Token struct {
+	Name     string
+	Address  common.Address
+	Decimals uint8
+}
 
-func (self *TokenApproveCmd) Run(cliContext *CLI, ctx context.Context, logger log.Logger) error {
-	_, filePath, err := prompt.ReadFile()
+func (self Token) IsETH() bool {
+	return self.Name == env.ETH_TOKEN.Name
+}
+
+// selectToken returns the token given by ETH, a symbol of the env tokens or a contract address
+// or prompts for one when the input is empty.
+// The decimals of ERC20 tokens that aren't in the env are read from the contract.
+func selectToken(ctx context.Context, cli *CLI, client bind.ContractCaller, netID int64, input string) (Token, error) {
+	custom, err := cli.CustomTokens(netID)
 	if err != nil {
-		return err
+		return Token{}, err
 	}
 
-	_tags, err := prompt.PromptInput("enter tags separated by a comma: ")
+	var token Token
+	switch {
+	case input == "":
+		var ok bool
+		token, ok, err = promptCustomToken(custom)
+		if err != nil {
+			return Token{}, err
+		}
+		if ok {
+			return token, nil
+		}
+		_token, err := prompt.Token(netID)
+		if err != nil {
+			return Token{}, err
+		}
+		if _token.Name == env.ETH_TOKEN.Name {
+			return Token{Name: _token.Name, Decimals: ethDecimals}, nil
+		}
+		tokenAddr, ok := _token.Address[netID]
+		if !ok {
+			return Token{}, errors.Errorf("unknown token address for network:%v", netID)
+		}
+		token = Token{Name: _token.Name, Address: tokenAddr}
+	case strings.EqualFold(input, env.ETH_TOKEN.Name):
+		return Token{Name: env.ETH_TOKEN.Name, Decimals: ethDecimals}, nil
+	default:
+		for _, t := range custom {
+			if strings.EqualFold(t.Name, input) || strings.EqualFold(t.Address.Hex(), input) {
+				return t, nil
+			}
+		}
+		tokenAddr, err := parseAddress(input)
+		if err != nil {
+			return Token{}, err
+		}
+		token = Token{Name: tokenAddr.Hex(), Address: tokenAddr}
+	}
+
+	decimals, err := tokenDecimals(ctx, client, token.Address)
+	if err != nil {
+		return Token{}, errors.Wrapf(err, "reading decimals of token:%v", token.Name)
+	}
+	token.Decimals = decimals
+	return token, nil
+}
+
+type balanceReader interface {
+	bind.ContractBackend
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+}
+
+// tokenBalance returns the ETH or ERC20 balance of the account in base units.
+func tokenBalance(ctx context.Context, client balanceReader, token Token, account common.Address) (*big.Int, error) {
+	if token.IsETH() {
+		balance, err := client.BalanceAt(ctx, account, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "client.BalanceAt")
+		}
+		return balance, nil
+	}
+	erc20I, err := interfaces.NewIERC20(token.Address, client)
+	if err != nil {
+		return nil, errors.Wrap(err, "NewIERC20")
+	}
+	balance, err := erc20I.BalanceOf(&bind.CallOpts{Context: ctx}, account)
 	if err != nil {
-		return errors.Wrap(err, "prompt tags")
+		return nil, errors.Wrap(err, "erc20I.BalanceOf")
 	}
-	tags := strings.Split(_tags, ",")
+	return balance, nil
+}
+
+type TokenApproveCmd struct {
+	TokenApproveFlags
+	Gas
+}
 
-	e, err := env.LoadFromFile(filePath, tags...)
+// TokenApproveFlags are shared with the command that builds the TX for offline signing.
+type TokenApproveFlags struct {
+	Token               string  `optional:"" help:"token contract address or symbol of a custom token, prompted for when not set"`
+	From                string  `optional:"" env:"WALLGER_FROM" help:"sender address, prompted for when not set"`
+	To                  string  `optional:"" env:"WALLGER_TO" help:"spender contract address, prompted for when not set"`
+	Amount              string  `optional:"" help:"approve limit in token units, max or unlimited, prompted for when not set"`
+	Raw                 bool    `optional:"" help:"the approve limit is in token base units"`
+	Proxy               string  `optional:"" help:"proxy contract address to approve through"`
+	Nonce               *uint64 `optional:"" help:"TX nonce, prompted for when not set"`
+	AllowUnknownSpender bool    `optional:"" help:"allow unlimited approvals to spenders that aren't env contracts in non interactive runs"`
+}
+
+func (self *TokenApproveCmd) Run(cliContext *CLI, ctx context.Context, logger log.Logger) error {
+	e, err := cliContext.Load("enter tags separated by a comma: ")
 	if err != nil {
-		return errors.Wrap(err, "loading env from file")
+		return err
 	}
 
 	client, err := client_p.NewClientCachedNetID(ctx, logger, e.Nodes[0].URL)
@@ -53,20 +153,23 @@ func (self *TokenApproveCmd) Run(cliContext *CLI, ctx context.Context, logger lo
 		return errors.Wrap(err, "NewClientCachedNetID")
 	}
 
-	token, err := prompt.Token(client.NetworkID())
+	token, err := selectToken(ctx, cliContext, client, client.NetworkID(), self.Token)
 	if err != nil {
 		return errors.Wrap(err, "selectToken")
 	}
+	if token.IsETH() {
+		return errors.New("ETH doesn't support approvals")
+	}
 
 	firstRun := true
 	for {
-		senderAcc, pass, err := env.SelectAccountAndDecrypt(e.Accounts, firstRun, "Select sender's pub address:")
+		senderAcc, pass, err := self.selectSender(e.Accounts, self.From, firstRun, "Select sender's pub address:")
 		if err != nil {
-			return errors.Wrap(err, "SelectAccountAndDecrypt sender")
+			return errors.Wrap(err, "select sender")
 		}
 		firstRun = false
 
-		if env.IsEncryptedEnv(e) {
+		if pass != "" && env.IsEncryptedEnv(e) {
 			e, err = env.DecryptEnv(e, pass)
 			if err != nil {
 				return errors.Wrap(err, "DecryptEnv")
@@ -74,7 +177,13 @@ func (self *TokenApproveCmd) Run(cliContext *CLI, ctx context.Context, logger lo
 		}
 
 		var spender common.Address
-		for {
+		if self.To != "" {
+			spender, err = parseAddress(self.To)
+			if err != nil {
+				return errors.Wrap(err, "spender")
+			}
+		}
+		for self.To == "" {
 			_spender, err := prompt.PromptInput("Select spender contract: ")
 			if err != nil {
 				fmt.Println("prompt error for spender contract:", err)
@@ -88,75 +197,65 @@ func (self *TokenApproveCmd) Run(cliContext *CLI, ctx context.Context, logger lo
 			break
 		}
 
-		var amount float64
-		for {
-			amount, err = prompt.Float(token.Name+" approve limit: ", 0, 1000000)
+		amount, err := selectApproveAmount(logger, self.Amount, self.Raw, token, token.Name+" approve limit or max: ", maxUint256)
+		if err != nil {
+			return errors.Wrap(err, "select amount")
+		}
+		if amount.Cmp(unlimitedAllowance) >= 0 {
+			err = confirmUnlimited(cliContext, e.Contracts, spender, self.AllowUnknownSpender)
 			if err != nil {
-				return errors.Wrap(err, "select amount prompt")
+				return err
 			}
-			break
 		}
 
-		var erc20I *interfaces.IERC20
-
-		tokenAddr, ok := token.Address[client.NetworkID()]
-		if !ok {
-			return errors.Errorf("unknown token address for network:%v", client.NetworkID())
-		}
-		erc20I, err = interfaces.NewIERC20(tokenAddr, client)
-		if err != nil {
-			return errors.Wrap(err, "NewIERC20")
-		}
-
-		useProxy, err := prompt.PromptConfirm("use proxy?")
+		contract := token.Address
+		proxy, err := selectProxy(e.Contracts, self.Proxy, cliContext.Yes)
 		if err != nil {
 			return errors.Wrap(err, "select proxy")
 		}
-		if useProxy {
-			contract, _, err := prompt.Contract(e.Contracts, false, false)
-			if err != nil {
-				return errors.Wrap(err, "select contract")
-			}
-			erc20I, err = interfaces.NewIERC20(*contract, client)
-			if err != nil {
-				return errors.Wrap(err, "NewIERC20 through a proxy")
-			}
+		if proxy != nil {
+			contract = *proxy
 		}
 
-		gasPrice, err := prompt.Float("enter TX gas price(gwei): ", 0, 300)
+		erc20I, err := interfaces.NewIERC20(contract, client)
 		if err != nil {
-			return err
+			return errors.Wrap(err, "NewIERC20")
 		}
 
-		ethAcc, err := tx_p.AccountFromPrvKey(senderAcc.Priv)
+		fees, err := self.Fees(ctx, client, cliContext.Yes)
 		if err != nil {
-			return errors.Wrap(err, "AccountFromPrvKey")
+			return err
 		}
 
-		nonce, err := prompt.Nonce(ctx, client, ethAcc.PublicKey)
-		if err != nil {
-			return errors.Wrap(err, "selectNonce")
-		}
-		confirmed, err := prompt.PromptConfirm(fmt.Sprintf("Confirm approve of:%v from:%v, to:%v, amount:%v, gas price:%v", token.Name, senderAcc.Pub, spender, amount, gasPrice))
-		if err != nil || !confirmed {
-			return errors.New("canceled")
+		var nonce uint64
+		if self.Nonce != nil {
+			nonce = *self.Nonce
+			// Another run would replace the TX with the same nonce.
+			self.Nonce = nil
+		} else {
+			nonce, err = prompt.Nonce(ctx, client, senderAcc.Pub)
+			if err != nil {
+				return errors.Wrap(err, "selectNonce")
+			}
 		}
 
-		opts, err := tx_p.NewTxOpts(ctx, client, nonce, ethAcc, gasPrice, gasPrice, 150_000)
+		data, err := packCall(func(opts *bind.TransactOpts) (*types.Transaction, error) {
+			return erc20I.Approve(opts, spender, amount)
+		})
 		if err != nil {
-			return errors.Wrap(err, "NewTxOpts")
+			return errors.Wrap(err, "pack Approve")
 		}
 
-		tx, err := erc20I.Approve(opts, spender, big_p.FromFloatMul(amount, params.Ether))
+		err = self.sendTx(ctx, cliContext, client, senderAcc, nonce, fees, contract, nil, data,
+			fmt.Sprintf("approve of:%v from:%v, to:%v, amount:%v", token.Name, senderAcc.Pub, spender, formatApproveAmount(amount, token.Decimals, maxUint256)),
+		)
 		if err != nil {
-			return errors.Wrap(err, "Approve")
+			return err
 		}
 
-		fmt.Println("Tx Created", "nonce", nonce, "hash", tx.Hash())
-
-		anotherRun, err := prompt.PromptConfirm("Another run?")
+		anotherRun, err := self.anotherRun(cliContext)
 		if err != nil {
-			return errors.Wrap(err, "prompt for another run")
+			return err
 		}
 		if !anotherRun {
 			break
@@ -165,23 +264,45 @@ func (self *TokenApproveCmd) Run(cliContext *CLI, ctx context.Context, logger lo
 	return nil
 }
 
-type TokenTransferCmd struct{}
-
-func (self *TokenTransferCmd) Run(cliContext *CLI, ctx context.Context, logger log.Logger) error {
-	_, filePath, err := prompt.ReadFile()
-	if err != nil {
-		return err
+// confirmUnlimited warns about an unlimited approval to a spender that isn't an env contract
+// and requires a second confirmation.
+func confirmUnlimited(cli *CLI, contracts []env.Contract, spender common.Address, allowUnknown bool) error {
+	if envContains(contracts, spender) {
+		return nil
 	}
-
-	_tags, err := prompt.PromptInput("enter tags separated by a comma: ")
-	if err != nil {
-		return errors.Wrap(err, "prompt tags")
+	if cli.Yes && !allowUnknown {
+		return errors.Errorf("unlimited approval to:%v which isn't an env contract requires --allow-unknown-spender in non interactive runs", spender)
+	}
+	fmt.Fprintf(os.Stderr, "WARNING: unlimited approval to %v which isn't an env contract, it can transfer all current and future tokens of the account\n", spender)
+	if cli.Yes {
+		return nil
 	}
-	tags := strings.Split(_tags, ",")
+	confirmed, err := prompt.PromptConfirm(fmt.Sprintf("Confirm again the unlimited approval to:%v", spender))
+	if err != nil || !confirmed {
+		return errors.New("canceled")
+	}
+	return nil
+}
+
+type TokenTransferCmd struct {
+	TokenTransferFlags
+	Gas
+}
+
+// TokenTransferFlags are shared with the command that builds the TX for offline signing.
+type TokenTransferFlags struct {
+	Token  string  `optional:"" help:"ETH or token contract address, prompted for when not set"`
+	From   string  `optional:"" env:"WALLGER_FROM" help:"sender address, prompted for when not set"`
+	To     string  `optional:"" env:"WALLGER_TO" help:"receiver address, prompted for when not set"`
+	Amount string  `optional:"" help:"amount to transfer, prompted for when not set"`
+	Proxy  string  `optional:"" help:"proxy contract address to transfer through"`
+	Nonce  *uint64 `optional:"" help:"TX nonce, prompted for when not set"`
+}
 
-	e, err := env.LoadFromFile(filePath, tags...)
+func (self *TokenTransferCmd) Run(cliContext *CLI, ctx context.Context, logger log.Logger) error {
+	e, err := cliContext.Load("enter tags separated by a comma: ")
 	if err != nil {
-		return errors.Wrap(err, "loading env from file")
+		return err
 	}
 
 	client, err := client_p.NewClientCachedNetID(ctx, logger, e.Nodes[0].URL)
@@ -189,130 +310,95 @@ func (self *TokenTransferCmd) Run(cliContext *CLI, ctx context.Context, logger l
 		return errors.Wrap(err, "NewClientCachedNetID")
 	}
 
-	token, err := prompt.Token(client.NetworkID())
+	token, err := selectToken(ctx, cliContext, client, client.NetworkID(), self.Token)
 	if err != nil {
 		return errors.Wrap(err, "selectToken")
 	}
 
 	firstRun := true
 	for {
-		senderAcc, pass, err := env.SelectAccountAndDecrypt(e.Accounts, firstRun, "Select sender's pub address:")
+		senderAcc, pass, err := self.selectSender(e.Accounts, self.From, firstRun, "Select sender's pub address:")
 		if err != nil {
-			return errors.Wrap(err, "SelectAccountAndDecrypt sender")
+			return errors.Wrap(err, "select sender")
 		}
 		firstRun = false
 
-		if env.IsEncryptedEnv(e) {
+		if pass != "" && env.IsEncryptedEnv(e) {
 			e, err = env.DecryptEnv(e, pass)
 			if err != nil {
 				return errors.Wrap(err, "DecryptEnv")
 			}
 		}
 
-		// Deep copy to not modify the original slice.
-		var accountsAndContracts []env.Account
-		copier.CopyWithOption(&accountsAndContracts, e.Accounts, copier.Option{DeepCopy: true})
-		for _, contract := range e.Contracts {
-			accountsAndContracts = append(accountsAndContracts, env.Account{
-				Pub:  contract.Address,
-				Tags: append(contract.Tags, "contract"),
-			})
-		}
-
-		receiverAcc, err := env.SelectAccount(accountsAndContracts, false, "Select receiver's pub address:")
+		receiverAcc, err := selectAccount(accountsAndContracts(e), self.To, false, "Select receiver's pub address:")
 		if err != nil {
 			return errors.Wrap(err, "SelectAccount receiver")
 		}
 
-		var amount float64
-		for {
-			_amount, err := prompt.PromptInput(token.Name + " аmount: ")
-			if err != nil {
-				return errors.Wrap(err, "select amount prompt")
-			}
-			amount, err = strconv.ParseFloat(_amount, 64)
-			if err != nil {
-				level.Error(logger).Log("msg", "casting input to float", "err", err)
-				continue
-			}
-
-			break
-		}
-
-		gasPrice, err := prompt.Float("enter TX gas price(gwei): ", 0, 300)
+		amount, err := selectAmount(logger, self.Amount, token, token.Name+" аmount: ")
 		if err != nil {
-			return err
+			return errors.Wrap(err, "select amount")
 		}
 
-		ethAcc, err := tx_p.AccountFromPrvKey(senderAcc.Priv)
+		fees, err := self.Fees(ctx, client, cliContext.Yes)
 		if err != nil {
-			return errors.Wrap(err, "AccountFromPrvKey")
+			return err
 		}
 
-		nonce, err := prompt.Nonce(ctx, client, ethAcc.PublicKey)
-		if err != nil {
-			return errors.Wrap(err, "selectNonce")
-		}
-
-		var tx *types.Transaction
-		if token.Name == env.ETH_TOKEN.Name {
-			tx, _, err = tx_p.NewSignedTX(
-				ctx,
-				ethAcc.PrivateKey,
-				receiverAcc.Pub,
-				"",
-				nonce,
-				client.NetworkID(),
-				"",
-				nil,
-				21_000,
-				gasPrice,
-				gasPrice,
-				amount,
-			)
-			if err != nil {
-				return errors.Wrap(err, "NewSignedTX")
-			}
-			err = client.SendTransaction(ctx, tx)
-			if err != nil {
-				fmt.Println("SendTransaction", "err", err.Error())
-				continue
-
-			}
+		var nonce uint64
+		if self.Nonce != nil {
+			nonce = *self.Nonce
+			// Another run would replace the TX with the same nonce.
+			self.Nonce = nil
 		} else {
-			erc20I, err := interfaces.NewIERC20(token.Address[client.NetworkID()], client)
+			nonce, err = prompt.Nonce(ctx, client, senderAcc.Pub)
 			if err != nil {
-				return errors.Wrap(err, "NewIERC20")
+				return errors.Wrap(err, "selectNonce")
 			}
+		}
 
-			proxy, _, err := prompt.Contract(e.Contracts, false, true)
+		// ETH is sent directly and tokens through a call to the token or the proxy contract.
+		to, value := receiverAcc.Pub, amount
+		var data []byte
+		if !token.IsETH() {
+			to, value = token.Address, nil
+			proxy, err := selectProxy(e.Contracts, self.Proxy, cliContext.Yes)
 			if err != nil {
 				return errors.Wrap(err, "selectProxy")
 			}
 			if proxy != nil {
-				erc20I, err = interfaces.NewIERC20(*proxy, client)
-				if err != nil {
-					return errors.Wrap(err, "NewIERC20 through a proxy")
-				}
+				to = *proxy
 			}
 
-			opts, err := tx_p.NewTxOpts(ctx, client, nonce, ethAcc, gasPrice, gasPrice, 150_000)
+			erc20I, err := interfaces.NewIERC20(to, client)
 			if err != nil {
-				return errors.Wrap(err, "NewTxOpts")
+				return errors.Wrap(err, "NewIERC20")
 			}
-			tx, err = erc20I.Transfer(opts, receiverAcc.Pub, big_p.FromFloatMul(amount, params.Ether))
+			data, err = packCall(func(opts *bind.TransactOpts) (*types.Transaction, error) {
+				return erc20I.Transfer(opts, receiverAcc.Pub, amount)
+			})
 			if err != nil {
-				fmt.Println("Transfer", "err", err.Error())
-				continue
+				return errors.Wrap(err, "pack Transfer")
 			}
-
 		}
 
-		fmt.Println("Tx Created", "nonce", nonce, "hash", tx.Hash())
+		err = self.sendTx(ctx, cliContext, client, senderAcc, nonce, fees, to, value, data,
+			fmt.Sprintf("transfer of:%v from:%v, to:%v, amount:%v", token.Name, senderAcc.Pub, receiverAcc.Pub, FormatAmount(amount, token.Decimals)),
+		)
+		if err != nil {
+			if cliContext.Yes {
+				return err
+			}
+			err = cliContext.Print(ErrorResult{Error: err.Error()})
+			if err != nil {
+				return err
+			}
+			continue
+		}
 
-		anotherRun, err := prompt.PromptConfirm("Another run?")
+		anotherRun, err := self.anotherRun(cliContext)
 		if err != nil {
-			return errors.Wrap(err, "prompt for another run")
+			return err
 		}
 		if !anotherRun {
 			break
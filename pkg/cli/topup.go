@@ -0,0 +1,162 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	client_p "github.com/cryptoriums/packages/client"
+	"github.com/cryptoriums/packages/env"
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+)
+
+type AccountTopupCmd struct {
+	From       string   `optional:"" env:"WALLGER_FROM" help:"funding account address, prompted for when not set"`
+	TargetTags []string `optional:"" name:"target-tag" help:"tags of the accounts to top up, all env accounts when not set"`
+	Min        string   `required:"" help:"ETH balance below which an account is topped up"`
+	Target     string   `optional:"" help:"ETH balance to top up to, defaults to the min balance"`
+	MaxTotal   string   `optional:"" help:"max total ETH to send to all accounts"`
+	DryRun     bool     `optional:"" help:"only show the top ups without sending them"`
+	Gas
+}
+
+func (self *AccountTopupCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	minBalance, err := ParseAmount(self.Min, ethDecimals)
+	if err != nil {
+		return errors.Wrap(err, "min balance")
+	}
+	target := minBalance
+	if self.Target != "" {
+		target, err = ParseAmount(self.Target, ethDecimals)
+		if err != nil {
+			return errors.Wrap(err, "target balance")
+		}
+		if target.Cmp(minBalance) < 0 {
+			return errors.New("target balance is lower than the min balance")
+		}
+	}
+	var maxTotal *big.Int
+	if self.MaxTotal != "" {
+		maxTotal, err = ParseAmount(self.MaxTotal, ethDecimals)
+		if err != nil {
+			return errors.Wrap(err, "max total")
+		}
+	}
+
+	e, err := cli.Load("enter tags separated by a comma: ")
+	if err != nil {
+		return err
+	}
+
+	client, err := client_p.NewClientCachedNetID(ctx, logger, e.Nodes[0].URL)
+	if err != nil {
+		return errors.Wrap(err, "NewClientCachedNetID")
+	}
+
+	// The funding account signs the top ups so it must be in the env.
+	var funder env.Account
+	if self.From != "" {
+		funder, err = findAccount(e.Accounts, self.From)
+	} else {
+		funder, err = env.SelectAccount(e.Accounts, false, "Select funding account pub address:")
+	}
+	if err != nil {
+		return errors.Wrap(err, "select funding account")
+	}
+
+	result := TopupResult{From: funder.Pub}
+	total := big.NewInt(0)
+	var targets []env.Account
+	var amounts []*big.Int
+	for _, acc := range e.Accounts {
+		if acc.Pub == funder.Pub || (len(self.TargetTags) > 0 && !hasAnyTag(acc.Tags, self.TargetTags)) {
+			continue
+		}
+		balance, err := client.BalanceAt(ctx, acc.Pub, nil)
+		if err != nil {
+			return errors.Wrap(err, "client.BalanceAt")
+		}
+		if balance.Cmp(minBalance) >= 0 {
+			continue
+		}
+		amount := new(big.Int).Sub(target, balance)
+		total.Add(total, amount)
+		targets = append(targets, acc)
+		amounts = append(amounts, amount)
+		result.Topups = append(result.Topups, Topup{
+			Address: acc.Pub,
+			Tags:    acc.Tags,
+			Balance: FormatAmount(balance, ethDecimals),
+			Amount:  FormatAmount(amount, ethDecimals),
+		})
+	}
+	result.Total = FormatAmount(total, ethDecimals)
+	maxTotalExceeded := maxTotal != nil && total.Cmp(maxTotal) > 0
+	if maxTotalExceeded {
+		result.AboveMaxTotal = FormatAmount(maxTotal, ethDecimals)
+	}
+
+	err = cli.Print(result)
+	if err != nil {
+		return err
+	}
+	if self.DryRun || len(targets) == 0 {
+		return nil
+	}
+	if maxTotalExceeded {
+		return errors.Errorf("total top up:%v is above the max total:%v", result.Total, result.AboveMaxTotal)
+	}
+
+	fees, err := self.Fees(ctx, client, cli.Yes)
+	if err != nil {
+		return err
+	}
+
+	nonce, err := client.PendingNonceAt(ctx, funder.Pub)
+	if err != nil {
+		return errors.Wrap(err, "PendingNonceAt")
+	}
+
+	eth := Token{Name: env.ETH_TOKEN.Name, Decimals: ethDecimals}
+	needed := new(big.Int).Set(total)
+	var txs []batchTx
+	for i, acc := range targets {
+		action := fmt.Sprintf("top up of:%v from:%v, amount:%v", acc.Pub, funder.Pub, FormatAmount(amounts[i], ethDecimals))
+		unsigned, err := self.buildTx(ctx, cli, client, funder.Pub, nonce+uint64(i), fees, acc.Pub, amounts[i], nil, action)
+		if err != nil {
+			return errors.Wrapf(err, "top up of:%v", acc.Pub)
+		}
+		needed.Add(needed, maxTxFee(unsigned))
+		txs = append(txs, batchTx{from: funder, token: eth, amount: amounts[i], unsigned: unsigned})
+	}
+
+	balance, err := client.BalanceAt(ctx, funder.Pub, nil)
+	if err != nil {
+		return errors.Wrap(err, "client.BalanceAt")
+	}
+	if balance.Cmp(needed) < 0 {
+		return errors.Errorf("funding account balance:%v is lower than the top ups with the max TX fees:%v", FormatAmount(balance, ethDecimals), FormatAmount(needed, ethDecimals))
+	}
+
+	err = cli.Confirm(fmt.Sprintf("Confirm %v top ups with total:%v ETH, %v", len(txs), result.Total, fees))
+	if err != nil {
+		return err
+	}
+
+	return self.sendBatch(ctx, cli, logger, client, e.Accounts, txs)
+}
+
+func hasAnyTag(tags []string, selected []string) bool {
+	for _, tag := range tags {
+		for _, s := range selected {
+			if tag == s {
+				return true
+			}
+		}
+	}
+	return false
+}
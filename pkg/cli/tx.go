@@ -0,0 +1,170 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+
+	client_p "github.com/cryptoriums/packages/client"
+	"github.com/cryptoriums/packages/prompt"
+	tx_p "github.com/cryptoriums/packages/tx"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+)
+
+// minReplaceBump is the minimum fee increase in percents
+// that the mempool requires to replace a pending TX.
+const minReplaceBump = 10
+
+const (
+	ReplaceCancel  = "cancel"
+	ReplaceSpeedUp = "speed-up"
+)
+
+type TxCmd struct {
+	Replace   TxReplaceCmd   `cmd:"" help:"cancel or speed up a pending TX by replacing it with the same nonce"`
+	Build     TxBuildCmd     `cmd:"" help:"build an unsigned TX on an online machine to be signed offline"`
+	Sign      TxSignCmd      `cmd:"" help:"sign a TX built by tx build without a node connection"`
+	Broadcast TxBroadcastCmd `cmd:"" help:"broadcast a TX signed by tx sign"`
+}
+
+type TxReplaceCmd struct {
+	Mode string  `arg:"" enum:"cancel,speed-up" help:"cancel sends 0 ETH to the sender, speed-up resends the same TX with higher fees"`
+	Hash string  `optional:"" help:"hash of the pending TX, prompted for when not set"`
+	Bump float64 `default:"10" help:"fee increase in percents for both the max fee and the tip, must be at least 10"`
+	WaitFlags
+}
+
+func (self *TxReplaceCmd) Run(cli *CLI, ctx context.Context, logger log.Logger) error {
+	if self.Bump < minReplaceBump {
+		return errors.Errorf("fee bump:%v is lower than the minimum for a replacement:%v", self.Bump, minReplaceBump)
+	}
+
+	e, err := cli.Load("enter tags separated by a comma: ")
+	if err != nil {
+		return err
+	}
+
+	client, err := client_p.NewClientCachedNetID(ctx, logger, e.Nodes[0].URL)
+	if err != nil {
+		return errors.Wrap(err, "NewClientCachedNetID")
+	}
+
+	hash := self.Hash
+	if hash == "" {
+		hash, err = prompt.PromptInput("TX hash to replace: ")
+		if err != nil {
+			return errors.Wrap(err, "TX hash input prompt")
+		}
+	}
+
+	tx, isPending, err := client.TransactionByHash(ctx, common.HexToHash(hash))
+	if err != nil {
+		return errors.Wrap(err, "TransactionByHash")
+	}
+	if !isPending {
+		return errors.New("TX is not in pending state")
+	}
+
+	signer := types.LatestSignerForChainID(big.NewInt(client.NetworkID()))
+
+	sender, err := signer.Sender(tx)
+	if err != nil {
+		return errors.Wrap(err, "signer.Sender")
+	}
+
+	senderAcc, _, err := selectAccountAndDecrypt(e.Accounts, sender.Hex(), false, "")
+	if err != nil {
+		return errors.Wrap(err, "TX sender")
+	}
+	acc, err := tx_p.AccountFromPrvKey(senderAcc.Priv)
+	if err != nil {
+		return errors.Wrap(err, "AccountFromPrvKey")
+	}
+
+	// Legacy TXs are replaced with legacy ones since the chain might not support EIP-1559.
+	fees := Fees{GasPrice: bumpFee(tx.GasPrice(), self.Bump)}
+	if tx.Type() != types.LegacyTxType {
+		fees = Fees{MaxFee: bumpFee(tx.GasFeeCap(), self.Bump), Tip: bumpFee(tx.GasTipCap(), self.Bump)}
+	}
+
+	to, value, data, gasLimit := tx.To(), tx.Value(), tx.Data(), tx.Gas()
+	if self.Mode == ReplaceCancel {
+		to, value, data, gasLimit = &acc.PublicKey, big.NewInt(0), nil, 21_000
+	}
+
+	err = cli.Confirm(fmt.Sprintf("Confirm %v of TX:%v nonce:%v, max fee:%v->%v gwei, priority fee:%v->%v gwei",
+		self.Mode, tx.Hash(), tx.Nonce(),
+		FormatAmount(tx.GasFeeCap(), gweiDecimals), FormatAmount(fees.Cap(), gweiDecimals),
+		FormatAmount(tx.GasTipCap(), gweiDecimals), FormatAmount(fees.Tip, gweiDecimals),
+	))
+	if err != nil {
+		return err
+	}
+
+	replacement, err := newSignedTx(acc.PrivateKey, client.NetworkID(), tx.Nonce(), to, value, data, gasLimit, fees)
+	if err != nil {
+		return errors.Wrap(err, "newSignedTx")
+	}
+
+	err = client.SendTransaction(ctx, replacement)
+	if err != nil {
+		return errors.Wrap(err, "SendTransaction")
+	}
+
+	err = cli.Print(TxResult{Hash: replacement.Hash(), Nonce: replacement.Nonce(), From: acc.PublicKey, Replaces: tx.Hash()})
+	if err != nil {
+		return err
+	}
+
+	_, err = self.WaitReceipt(ctx, cli, client, replacement, acc.PublicKey)
+	return err
+}
+
+// bumpFee increases the fee by the given percents rounding up
+// so that the result is never below the mempool replacement threshold.
+func bumpFee(fee *big.Int, percents float64) *big.Int {
+	// Scale the percents to allow fractions like 12.5.
+	mul := big.NewInt(int64(percents*100) + 10_000)
+	bumped := new(big.Int).Mul(fee, mul)
+	bumped.Add(bumped, big.NewInt(9_999))
+	return bumped.Div(bumped, big.NewInt(10_000))
+}
+
+// newSignedTx creates and signs a dynamic fee TX or a legacy one when the fees are legacy.
+// Unlike tx_p.NewSignedTX the value and fees are in wei so no precision is lost.
+func newSignedTx(prv *ecdsa.PrivateKey, netID int64, nonce uint64, to *common.Address, value *big.Int, data []byte, gasLimit uint64, fees Fees) (*types.Transaction, error) {
+	var tx *types.Transaction
+	if fees.Legacy() {
+		tx = types.NewTx(&types.LegacyTx{
+			Nonce:    nonce,
+			GasPrice: fees.GasPrice,
+			Gas:      gasLimit,
+			To:       to,
+			Value:    value,
+			Data:     data,
+		})
+	} else {
+		tx = types.NewTx(&types.DynamicFeeTx{
+			ChainID:   big.NewInt(netID),
+			Nonce:     nonce,
+			GasFeeCap: fees.MaxFee,
+			GasTipCap: fees.Tip,
+			Gas:       gasLimit,
+			To:        to,
+			Value:     value,
+			Data:      data,
+		})
+	}
+	signed, err := types.SignTx(tx, types.LatestSignerForChainID(big.NewInt(netID)), prv)
+	if err != nil {
+		return nil, errors.Wrap(err, "SignTx")
+	}
+	return signed, nil
+}
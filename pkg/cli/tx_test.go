@@ -0,0 +1,30 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestBumpFee(t *testing.T) {
+	tests := []struct {
+		fee      int64
+		percents float64
+		want     int64
+	}{
+		{fee: 100, percents: 10, want: 110},
+		{fee: 100, percents: 12.5, want: 113},
+		{fee: 1_000_000_000, percents: 10, want: 1_100_000_000},
+		// Rounded up so the bump is never below the percents.
+		{fee: 1, percents: 10, want: 2},
+		{fee: 0, percents: 10, want: 0},
+		{fee: 100, percents: 0, want: 100},
+	}
+	for _, tt := range tests {
+		if got := bumpFee(big.NewInt(tt.fee), tt.percents); got.Cmp(big.NewInt(tt.want)) != 0 {
+			t.Errorf("bumpFee(%v, %v) got:%v, want:%v", tt.fee, tt.percents, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright (c) The Cryptorium Authors.
+// Licensed under the MIT License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+)
+
+// ExitCodeReverted is the exit status when a sent TX reverts.
+const ExitCodeReverted = 2
+
+// ErrReverted is returned when the receipt of a sent TX has a failed status.
+var ErrReverted = errors.New("TX reverted")
+
+const (
+	receiptPollInterval = 3 * time.Second
+	// droppedAfterPolls is the number of polls for which the node doesn't know about the TX
+	// before it is considered dropped so that nodes behind a load balancer have time to see it.
+	droppedAfterPolls = 5
+)
+
+type receiptBackend interface {
+	bind.ContractBackend
+	ethereum.TransactionReader
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+}
+
+// WaitFlags select whether to wait for the receipt of a sent TX.
+type WaitFlags struct {
+	Wait          bool          `optional:"" env:"WALLGER_WAIT" help:"wait for the TX receipt and exit with status 2 when the TX reverts"`
+	Confirmations uint64        `default:"1" help:"number of blocks to wait for after the TX is mined when waiting for the receipt"`
+	WaitTimeout   time.Duration `default:"10m" help:"max time to wait for the TX receipt"`
+}
+
+// WaitReceipt waits for the TX receipt and prints it when the wait mode is enabled.
+// It returns ErrReverted when the TX reverts and a nil receipt when not waiting.
+func (self *WaitFlags) WaitReceipt(ctx context.Context, cli *CLI, client receiptBackend, tx *types.Transaction, from common.Address) (*types.Receipt, error) {
+	if !self.Wait {
+		return nil, nil
+	}
+	if self.Confirmations == 0 {
+		return nil, errors.New("confirmations should be at least 1")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, self.WaitTimeout)
+	defer cancel()
+
+	receipt, confirmations, err := waitReceipt(ctx, client, tx, from, self.Confirmations)
+	if err != nil {
+		return nil, err
+	}
+
+	gasPrice, err := effectiveGasPrice(ctx, client, tx, receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	result := ReceiptResult{
+		Hash:              tx.Hash(),
+		Status:            ReceiptSuccess,
+		Block:             receipt.BlockNumber.Uint64(),
+		BlockHash:         receipt.BlockHash,
+		GasUsed:           receipt.GasUsed,
+		EffectiveGasPrice: gasPrice.String(),
+		Confirmations:     confirmations,
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		result.Status = ReceiptReverted
+	}
+	err = cli.Print(result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Status == ReceiptReverted {
+		return receipt, errors.Wrapf(ErrReverted, "TX:%v", tx.Hash())
+	}
+	return receipt, nil
+}
+
+// waitReceipt polls for the TX receipt until the TX has the given number of confirmations.
+// When the block of the receipt changes in a reorg the confirmations start from the new block.
+// The TX is considered dropped when the node no longer knows about it
+// and replaced when another TX with the same nonce is mined.
+func waitReceipt(ctx context.Context, client receiptBackend, tx *types.Transaction, from common.Address, confirmations uint64) (*types.Receipt, uint64, error) {
+	var minedIn common.Hash
+	var unknownPolls int
+	for {
+		receipt, err := client.TransactionReceipt(ctx, tx.Hash())
+		switch {
+		case err == nil:
+			if minedIn != (common.Hash{}) && minedIn != receipt.BlockHash {
+				fmt.Fprintln(os.Stderr, "TX block changed in a reorg, new block:", receipt.BlockNumber)
+			}
+			minedIn = receipt.BlockHash
+
+			head, err := client.HeaderByNumber(ctx, nil)
+			if err != nil {
+				return nil, 0, errors.Wrap(err, "HeaderByNumber")
+			}
+			var current uint64
+			if head.Number.Cmp(receipt.BlockNumber) >= 0 {
+				current = new(big.Int).Sub(head.Number, receipt.BlockNumber).Uint64() + 1
+			}
+			if current >= confirmations {
+				return receipt, current, nil
+			}
+		case errors.Is(err, ethereum.NotFound):
+			if minedIn != (common.Hash{}) {
+				fmt.Fprintln(os.Stderr, "TX removed from its block in a reorg, waiting for it to be mined again")
+				minedIn = common.Hash{}
+			}
+
+			_, _, err := client.TransactionByHash(ctx, tx.Hash())
+			if err != nil && !errors.Is(err, ethereum.NotFound) {
+				return nil, 0, errors.Wrap(err, "TransactionByHash")
+			}
+			unknownPolls++
+			if err == nil {
+				unknownPolls = 0
+			}
+			if unknownPolls >= droppedAfterPolls {
+				nonce, err := client.NonceAt(ctx, from, nil)
+				if err != nil {
+					return nil, 0, errors.Wrap(err, "NonceAt")
+				}
+				if nonce > tx.Nonce() {
+					return nil, 0, errors.Errorf("TX:%v was replaced by another TX with the same nonce:%v", tx.Hash(), tx.Nonce())
+				}
+				return nil, 0, errors.Errorf("TX:%v was dropped from the mempool", tx.Hash())
+			}
+		default:
+			return nil, 0, errors.Wrap(err, "TransactionReceipt")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, 0, errors.Wrapf(ctx.Err(), "waiting for TX:%v", tx.Hash())
+		case <-time.After(receiptPollInterval):
+		}
+	}
+}
+
+// effectiveGasPrice returns the price per gas paid by the mined TX.
+// For dynamic fee TXs it is the base fee of the block plus the tip limited by the max fee.
+func effectiveGasPrice(ctx context.Context, client receiptBackend, tx *types.Transaction, receipt *types.Receipt) (*big.Int, error) {
+	if tx.Type() == types.LegacyTxType || tx.Type() == types.AccessListTxType {
+		return tx.GasPrice(), nil
+	}
+	header, err := client.HeaderByNumber(ctx, receipt.BlockNumber)
+	if err != nil {
+		return nil, errors.Wrap(err, "HeaderByNumber")
+	}
+	price := tx.EffectiveGasTipValue(header.BaseFee)
+	return price.Add(price, header.BaseFee), nil
+}